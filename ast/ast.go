@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -14,6 +15,8 @@ type Node interface {
 type Statement interface {
 	Node
 	statementNode()
+	Pos() Position
+	SetPos(Position)
 }
 
 type Expression interface {
@@ -21,6 +24,19 @@ type Expression interface {
 	expressionNode()
 }
 
+// Position locates a statement or an expression in its source, lines and
+// columns count from 1. Nodes made by macros or the optimizer have the zero
+// Position.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) Pos() Position { return p }
+
+// SetPos is called by the parser once the node is parsed.
+func (p *Position) SetPos(position Position) { *p = position }
+
 type Program struct {
 	Statements []Statement
 }
@@ -36,7 +52,18 @@ func (p *Program) String() string {
 }
 
 type Identifier struct {
+	Position
 	Value string
+	// Set by the resolver for local variables, globals and identifiers which
+	// were not resolved are looked up by name
+	Slot *Slot
+}
+
+// Slot locates a local variable in the frame Depth frames out of the current
+// one.
+type Slot struct {
+	Depth int
+	Index int
 }
 
 func (i *Identifier) expressionNode() {}
@@ -51,6 +78,13 @@ type IntegerLiteral struct {
 func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) String() string  { return strconv.FormatInt(il.Value, 10) }
 
+type BigIntegerLiteral struct {
+	Value *big.Int
+}
+
+func (bil *BigIntegerLiteral) expressionNode() {}
+func (bil *BigIntegerLiteral) String() string  { return bil.Value.String() }
+
 type StringLiteral struct {
 	Value string
 }
@@ -59,7 +93,33 @@ func (sl *StringLiteral) expressionNode()    {}
 func (sl *StringLiteral) String() string     { return "\"" + sl.Value + "\"" }
 func (sl *StringLiteral) PureString() string { return sl.Value }
 
+// InterpolatedString is a string literal with embedded expressions, Strings
+// has one more element than Expressions and they interleave starting with it.
+type InterpolatedString struct {
+	Strings     []string
+	Expressions []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for i, str := range is.Strings {
+		out.WriteString(str)
+		if i < len(is.Expressions) {
+			out.WriteString("${")
+			out.WriteString(is.Expressions[i].String())
+			out.WriteString("}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
 type PrefixExpression struct {
+	Position
 	Operator string
 	Right    Expression
 }
@@ -77,6 +137,7 @@ func (pe *PrefixExpression) String() string {
 }
 
 type InfixExpression struct {
+	Position
 	Left     Expression
 	Operator string
 	Right    Expression
@@ -115,6 +176,22 @@ type IfExpression struct {
 }
 
 func (ie *IfExpression) expressionNode() {}
+
+// ElseIf returns the nested if expression of an `else if` chain, `else if`
+// is parsed as an alternative block holding only that if expression.
+func (ie *IfExpression) ElseIf() *IfExpression {
+	if ie.Alternative == nil || len(ie.Alternative.Statements) != 1 {
+		return nil
+	}
+
+	statement, ok := ie.Alternative.Statements[0].(*ExpressionStatement)
+	if !ok {
+		return nil
+	}
+
+	elseIf, _ := statement.Expression.(*IfExpression)
+	return elseIf
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -125,15 +202,229 @@ func (ie *IfExpression) String() string {
 
 	if ie.Alternative != nil {
 		out.WriteString(" else ")
-		out.WriteString(ie.Alternative.String())
+		if elseIf := ie.ElseIf(); elseIf != nil {
+			out.WriteString(elseIf.String())
+		} else {
+			out.WriteString(ie.Alternative.String())
+		}
+	}
+
+	return out.String()
+}
+
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression
+	// Either an Expression or a *BlockStatement
+	Body Node
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
 	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+}
+
+type MatchExpression struct {
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode() {}
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match ")
+	out.WriteString(me.Subject.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// SpawnExpression runs Call on a separate task.
+type SpawnExpression struct {
+	Call *CallExpression
+}
+
+func (se *SpawnExpression) expressionNode() {}
+func (se *SpawnExpression) String() string {
+	return "(spawn " + se.Call.String() + ")"
+}
+
+// SelectCase is `recv(channel)`, `send(channel, value)` or the default `_`,
+// a received value may be bound as in `x = recv(channel)`.
+type SelectCase struct {
+	Name *Identifier
+	Call *CallExpression // Nil for the default case
+	// Either an Expression or a *BlockStatement
+	Body Node
+}
+
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+
+	if sc.Name != nil {
+		out.WriteString(sc.Name.String() + " = ")
+	}
+
+	if sc.Call != nil {
+		out.WriteString(sc.Call.String())
+	} else {
+		out.WriteString("_")
+	}
+
+	out.WriteString(" => ")
+	out.WriteString(sc.Body.String())
+
+	return out.String()
+}
+
+type SelectExpression struct {
+	Cases []*SelectCase
+}
+
+func (se *SelectExpression) expressionNode() {}
+func (se *SelectExpression) String() string {
+	cases := []string{}
+	for _, c := range se.Cases {
+		cases = append(cases, c.String())
+	}
+
+	return "select { " + strings.Join(cases, ", ") + " }"
+}
+
+// Pattern is the left side of a match arm.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// WildcardPattern `_` matches anything without binding it.
+type WildcardPattern struct{}
+
+func (wp *WildcardPattern) patternNode()   {}
+func (wp *WildcardPattern) String() string { return "_" }
+
+// BindingPattern matches anything and binds it to Name.
+type BindingPattern struct {
+	Name *Identifier
+}
+
+func (bp *BindingPattern) patternNode()   {}
+func (bp *BindingPattern) String() string { return bp.Name.String() }
+
+// LiteralPattern matches values equal to Value.
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()   {}
+func (lp *LiteralPattern) String() string { return lp.Value.String() }
+
+// RangePattern matches values in `Low..High` or `Low..=High`.
+type RangePattern struct {
+	Low       Expression
+	High      Expression
+	Inclusive bool
+}
+
+func (rp *RangePattern) patternNode() {}
+func (rp *RangePattern) String() string {
+	if rp.Inclusive {
+		return rp.Low.String() + "..=" + rp.High.String()
+	}
+	return rp.Low.String() + ".." + rp.High.String()
+}
+
+// ArrayPattern matches arrays element by element, with HasRest the array may
+// be longer and the remaining elements are bound to Rest if it is not nil.
+type ArrayPattern struct {
+	Elements []Pattern
+	HasRest  bool
+	Rest     *Identifier
+}
+
+func (ap *ArrayPattern) patternNode() {}
+func (ap *ArrayPattern) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+
+	if ap.HasRest {
+		if ap.Rest != nil {
+			elements = append(elements, ".."+ap.Rest.String())
+		} else {
+			elements = append(elements, "..")
+		}
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type MapPatternPair struct {
+	Key     Expression
+	Pattern Pattern
+}
+
+// MapPattern matches maps containing all of its keys, extra keys are ignored.
+type MapPattern struct {
+	Pairs []*MapPatternPair
+}
+
+func (mp *MapPattern) patternNode() {}
+func (mp *MapPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range mp.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.String(), pair.Pattern.String()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
 
 	return out.String()
 }
 
 type FunctionLiteral struct {
+	Name       string // Empty for anonymous functions
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// Optional annotations, nil entries for unannotated parameters
+	ParameterTypes []*Identifier
+	ReturnType     *Identifier
+	Body           *BlockStatement
+	IsGenerator    bool // Body yields
+}
+
+// parameterString prints the i-th parameter with its annotation.
+func (fl *FunctionLiteral) parameterString(i int) string {
+	if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+		return fl.Parameters[i].String() + ": " + fl.ParameterTypes[i].String()
+	}
+	return fl.Parameters[i].String()
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -141,19 +432,29 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i := range fl.Parameters {
+		params = append(params, fl.parameterString(i))
 	}
 
-	out.WriteString("|")
-	out.WriteString(strings.Join(params, ", "))
-	out.WriteString("| ")
+	if fl.Name != "" {
+		out.WriteString("fn " + fl.Name + "(")
+		out.WriteString(strings.Join(params, ", "))
+		out.WriteString(") ")
+	} else {
+		out.WriteString("|")
+		out.WriteString(strings.Join(params, ", "))
+		out.WriteString("| ")
+	}
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
 type CallExpression struct {
+	Position
 	Function  Expression
 	Arguments []Expression
 }
@@ -176,7 +477,9 @@ func (ce *CallExpression) String() string {
 }
 
 type AssignStatement struct {
+	Position
 	Name  *Identifier
+	Type  *Identifier // Optional annotation
 	Value Expression
 }
 
@@ -185,6 +488,10 @@ func (as *AssignStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(as.Name.String())
+	if as.Type != nil {
+		out.WriteString(": ")
+		out.WriteString(as.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if as.Value != nil {
@@ -196,7 +503,189 @@ func (as *AssignStatement) String() string {
 	return out.String()
 }
 
+// FunctionStatement declares a named function, which is hoisted to the top
+// of its enclosing block.
+type FunctionStatement struct {
+	Position
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode() {}
+func (fs *FunctionStatement) String() string { return fs.Function.String() }
+
+// ImportStatement is `import "path"`, binding the module itself, or
+// `from "path" import a, b`, binding the listed names.
+type ImportStatement struct {
+	Position
+	Path  string
+	Names []*Identifier
+}
+
+func (is *ImportStatement) statementNode() {}
+func (is *ImportStatement) String() string {
+	if is.Names == nil {
+		return fmt.Sprintf("import %q;", is.Path)
+	}
+
+	names := []string{}
+	for _, name := range is.Names {
+		names = append(names, name.String())
+	}
+
+	return fmt.Sprintf("from %q import %s;", is.Path, strings.Join(names, ", "))
+}
+
+// ExportStatement makes the binding of an assignment or a function
+// declaration visible to importers.
+type ExportStatement struct {
+	Position
+	Statement Statement
+}
+
+func (es *ExportStatement) statementNode() {}
+func (es *ExportStatement) String() string {
+	return "export " + es.Statement.String()
+}
+
+// ExportedName returns the name bound by the exported statement.
+func (es *ExportStatement) ExportedName() string {
+	switch statement := es.Statement.(type) {
+	case *AssignStatement:
+		return statement.Name.Value
+	case *FunctionStatement:
+		return statement.Function.Name
+	case *StructStatement:
+		return statement.Name.Value
+	case *TraitStatement:
+		return statement.Name.Value
+	default:
+		return ""
+	}
+}
+
+// StructStatement declares a struct type with fixed fields, named after the
+// constructor it binds.
+type StructStatement struct {
+	Position
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode() {}
+func (ss *StructStatement) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, field := range ss.Fields {
+		fields = append(fields, field.String())
+	}
+
+	out.WriteString("struct ")
+	out.WriteString(ss.Name.String())
+	if len(fields) == 0 {
+		out.WriteString(" {}")
+		return out.String()
+	}
+
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// TraitStatement declares a trait as the names of the methods its
+// implementations must define.
+type TraitStatement struct {
+	Position
+	Name    *Identifier
+	Methods []*Identifier
+}
+
+func (ts *TraitStatement) statementNode() {}
+func (ts *TraitStatement) String() string {
+	var out bytes.Buffer
+
+	methods := []string{}
+	for _, method := range ts.Methods {
+		methods = append(methods, method.String())
+	}
+
+	out.WriteString("trait ")
+	out.WriteString(ts.Name.String())
+	if len(methods) == 0 {
+		out.WriteString(" {}")
+		return out.String()
+	}
+
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(methods, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// ImplStatement adds methods to a struct type, implementing Trait if given.
+type ImplStatement struct {
+	Position
+	Trait   *Identifier
+	Type    *Identifier
+	Methods []*FunctionLiteral
+}
+
+func (is *ImplStatement) statementNode() {}
+func (is *ImplStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("impl ")
+	if is.Trait != nil {
+		out.WriteString(is.Trait.String())
+		out.WriteString(" for ")
+	}
+	out.WriteString(is.Type.String())
+
+	if len(is.Methods) == 0 {
+		out.WriteString(" {}")
+		return out.String()
+	}
+
+	out.WriteString(" { ")
+	for i, method := range is.Methods {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString(method.String())
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// FieldAssignStatement assigns to a field, as in `p.x = 1`.
+type FieldAssignStatement struct {
+	Position
+	Target *MemberExpression
+	Value  Expression
+}
+
+func (fas *FieldAssignStatement) statementNode() {}
+func (fas *FieldAssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fas.Target.String())
+	out.WriteString(" = ")
+
+	if fas.Value != nil {
+		out.WriteString(fas.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
 type ReturnStatement struct {
+	Position
 	ReturnValue Expression
 }
 
@@ -216,6 +705,7 @@ func (rs *ReturnStatement) String() string {
 }
 
 type ExpressionStatement struct {
+	Position
 	Expression Expression
 }
 
@@ -225,7 +715,9 @@ func (es *ExpressionStatement) String() string {
 
 	if es.Expression != nil {
 		out.WriteString(es.Expression.String())
-		if _, ok := es.Expression.(*IfExpression); !ok {
+		switch es.Expression.(type) {
+		case *IfExpression, *MatchExpression, *SelectExpression:
+		default:
 			out.WriteString(";")
 		}
 	}
@@ -234,8 +726,10 @@ func (es *ExpressionStatement) String() string {
 }
 
 type BlockStatement struct {
+	Position
 	Statements []Statement
 	Ident      int
+	Names      []string // Slots of the frame, set by the resolver
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -253,6 +747,7 @@ func (bs *BlockStatement) String() string {
 }
 
 type WhileStatement struct {
+	Position
 	Condition Expression
 	Body      *BlockStatement
 }
@@ -268,14 +763,59 @@ func (ws *WhileStatement) String() string {
 	return out.String()
 }
 
-type BreakStatement struct{}
+type ForStatement struct {
+	Position
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for ")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(" ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type YieldExpression struct {
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode() {}
+func (ye *YieldExpression) String() string {
+	return "(yield " + ye.Value.String() + ")"
+}
+
+// SpreadExpression expands an iterable into the surrounding array literal,
+// tuple or call arguments.
+type SpreadExpression struct {
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode() {}
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+type BreakStatement struct {
+	Position
+}
 
 func (bs *BreakStatement) statementNode() {}
 func (bs *BreakStatement) String() string {
 	return "break;"
 }
 
-type ContinueStatement struct{}
+type ContinueStatement struct {
+	Position
+}
 
 func (cs *ContinueStatement) statementNode() {}
 func (cs *ContinueStatement) String() string {
@@ -302,7 +842,66 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// SliceExpression is `left[start:end:step]`, omitted parts are nil.
+type SliceExpression struct {
+	Position
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	optional := func(exp Expression) string {
+		if exp == nil {
+			return ""
+		}
+		return exp.String()
+	}
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	out.WriteString(optional(se.Start))
+	out.WriteString(":")
+	out.WriteString(optional(se.End))
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type TupleLiteral struct {
+	Elements []Expression
+}
+
+func (tl *TupleLiteral) expressionNode() {}
+func (tl *TupleLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range tl.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("(")
+	out.WriteString(strings.Join(elements, ", "))
+	if len(elements) == 1 {
+		out.WriteString(",")
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type IndexExpression struct {
+	Position
 	Left  Expression
 	Index Expression
 }
@@ -320,8 +919,24 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+type MemberExpression struct {
+	Position
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Member.String() + ")"
+}
+
+type MapPair struct {
+	Key   Expression
+	Value Expression
+}
+
 type MapLiteral struct {
-	Pairs map[Expression]Expression
+	Pairs []*MapPair
 }
 
 func (ml *MapLiteral) expressionNode() {}
@@ -330,8 +945,8 @@ func (ml *MapLiteral) String() string {
 
 	pairs := []string{}
 
-	for key, value := range ml.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", key.String(), value.String()))
+	for _, pair := range ml.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.String(), pair.Value.String()))
 	}
 
 	out.WriteString("{")
@@ -379,15 +994,36 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 	case *PrefixExpression:
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *MemberExpression:
+		node.Object, _ = Modify(node.Object, modifier).(Expression)
 	case *IndexExpression:
-		node.Left, _ = Modify(node.Index, modifier).(Expression)
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Index, _ = Modify(node.Index, modifier).(Expression)
+	case *SliceExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		if node.Start != nil {
+			node.Start, _ = Modify(node.Start, modifier).(Expression)
+		}
+		if node.End != nil {
+			node.End, _ = Modify(node.End, modifier).(Expression)
+		}
+		if node.Step != nil {
+			node.Step, _ = Modify(node.Step, modifier).(Expression)
+		}
 	case *IfExpression:
 		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
 		if node.Alternative != nil {
 			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
 		}
+	case *MatchExpression:
+		node.Subject, _ = Modify(node.Subject, modifier).(Expression)
+		for _, arm := range node.Arms {
+			if arm.Guard != nil {
+				arm.Guard, _ = Modify(arm.Guard, modifier).(Expression)
+			}
+			arm.Body = Modify(arm.Body, modifier)
+		}
 	case *BlockStatement:
 		for i := range node.Statements {
 			node.Statements[i], _ = Modify(node.Statements[i], modifier).(Statement)
@@ -396,6 +1032,17 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
 	case *AssignStatement:
 		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *FieldAssignStatement:
+		node.Target, _ = Modify(node.Target, modifier).(*MemberExpression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *FunctionStatement:
+		node.Function, _ = Modify(node.Function, modifier).(*FunctionLiteral)
+	case *ImplStatement:
+		for i, method := range node.Methods {
+			node.Methods[i], _ = Modify(method, modifier).(*FunctionLiteral)
+		}
+	case *ExportStatement:
+		node.Statement, _ = Modify(node.Statement, modifier).(Statement)
 	case *FunctionLiteral:
 		for i := range node.Parameters {
 			node.Parameters[i], _ = Modify(node.Parameters[i], modifier).(*Identifier)
@@ -406,17 +1053,44 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		for i := range node.Elements {
 			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
 		}
+	case *InterpolatedString:
+		for i := range node.Expressions {
+			node.Expressions[i], _ = Modify(node.Expressions[i], modifier).(Expression)
+		}
+	case *TupleLiteral:
+		for i := range node.Elements {
+			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
+		}
 	case *MapLiteral:
-		newPairs := make(map[Expression]Expression)
-		for key, val := range node.Pairs {
-			newKey, _ := Modify(key, modifier).(Expression)
-			newVal, _ := Modify(val, modifier).(Expression)
-			newPairs[newKey] = newVal
+		for _, pair := range node.Pairs {
+			pair.Key, _ = Modify(pair.Key, modifier).(Expression)
+			pair.Value, _ = Modify(pair.Value, modifier).(Expression)
 		}
-		node.Pairs = newPairs
 	case *WhileStatement:
 		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForStatement:
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *YieldExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *SpawnExpression:
+		// Calls replaced by something else, as by a macro, are kept
+		if call, ok := Modify(node.Call, modifier).(*CallExpression); ok {
+			node.Call = call
+		}
+	case *SelectExpression:
+		for _, c := range node.Cases {
+			if c.Call == nil {
+				continue
+			}
+			if call, ok := Modify(c.Call, modifier).(*CallExpression); ok {
+				c.Call = call
+			}
+			c.Body = Modify(c.Body, modifier)
+		}
+	case *SpreadExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
 	}
 
 	return modifier(node)
@@ -24,6 +24,23 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestMapLiteralString(t *testing.T) {
+	mapLiteral := &MapLiteral{
+		Pairs: []*MapPair{
+			{Key: &StringLiteral{Value: "b"}, Value: &IntegerLiteral{Value: 2}},
+			{Key: &StringLiteral{Value: "a"}, Value: &IntegerLiteral{Value: 1}},
+			{Key: &IntegerLiteral{Value: 3}, Value: &IntegerLiteral{Value: 3}},
+		},
+	}
+
+	expected := `{"b": 2, "a": 1, 3: 3}`
+	for i := 0; i < 10; i++ {
+		if mapLiteral.String() != expected {
+			t.Fatalf("mapLiteral.String() wrong, got=%q, want=%q", mapLiteral.String(), expected)
+		}
+	}
+}
+
 func TestModify(t *testing.T) {
 	one := func() Expression { return &IntegerLiteral{Value: 1} }
 	two := func() Expression { return &IntegerLiteral{Value: 2} }
@@ -78,6 +95,10 @@ func TestModify(t *testing.T) {
 			&IndexExpression{Left: one(), Index: one()},
 			&IndexExpression{Left: two(), Index: two()},
 		},
+		{
+			&IndexExpression{Left: &Identifier{Value: "a"}, Index: one()},
+			&IndexExpression{Left: &Identifier{Value: "a"}, Index: two()},
+		},
 		{
 			&IfExpression{
 				Condition: one(),
@@ -148,23 +169,45 @@ func TestModify(t *testing.T) {
 	}
 
 	mapLiteral := &MapLiteral{
-		Pairs: map[Expression]Expression{
-			one(): one(),
-			one(): one(),
+		Pairs: []*MapPair{
+			{Key: one(), Value: one()},
+			{Key: one(), Value: one()},
 		},
 	}
 
 	Modify(mapLiteral, turnOneIntoTwo)
 
-	for key, val := range mapLiteral.Pairs {
-		key, _ := key.(*IntegerLiteral)
+	for _, pair := range mapLiteral.Pairs {
+		key, _ := pair.Key.(*IntegerLiteral)
 		if key.Value != 2 {
 			t.Errorf("key is not %d, got=%d", 2, key.Value)
 		}
 
-		val, _ := val.(*IntegerLiteral)
-		if key.Value != 2 {
+		val, _ := pair.Value.(*IntegerLiteral)
+		if val.Value != 2 {
 			t.Errorf("value is not%d, got=%d", 2, val.Value)
 		}
 	}
 }
+
+func TestModifyKeepsCalls(t *testing.T) {
+	// As a macro call expanded into an expression which is not a call
+	callIntoOne := func(node Node) Node {
+		if _, ok := node.(*CallExpression); ok {
+			return &IntegerLiteral{Value: 1}
+		}
+		return node
+	}
+
+	spawn := &SpawnExpression{Call: &CallExpression{Function: &Identifier{Value: "m"}}}
+	Modify(spawn, callIntoOne)
+	if spawn.Call == nil {
+		t.Errorf("the call of spawn is lost")
+	}
+
+	selectCase := &SelectCase{Call: &CallExpression{Function: &Identifier{Value: "m"}}, Body: &IntegerLiteral{Value: 2}}
+	Modify(&SelectExpression{Cases: []*SelectCase{selectCase}}, callIntoOne)
+	if selectCase.Call == nil {
+		t.Errorf("the call of select case is lost")
+	}
+}
@@ -0,0 +1,50 @@
+package checker
+
+func signature(name string, ret Type, params ...Type) *Function {
+	return &Function{Name: name, Params: params, Return: ret}
+}
+
+var builtins = map[string]*Function{
+	"len":        signature("len", INT, ANY),
+	"first":      signature("first", ANY, ANY),
+	"last":       signature("last", ANY, ANY),
+	"rest":       signature("rest", ANY, ANY),
+	"push":       signature("push", ARRAY, ARRAY, ANY),
+	"keys":       signature("keys", ARRAY, MAP),
+	"values":     signature("values", ARRAY, MAP),
+	"iter":       signature("iter", ITERATOR, ANY),
+	"next":       signature("next", ANY, ITERATOR),
+	"done":       signature("done", BOOL, ITERATOR),
+	"array":      signature("array", ARRAY, ANY),
+	"take":       signature("take", ITERATOR, ANY, INT),
+	"chan":       {Name: "chan", Return: CHAN, Variadic: true},
+	"send":       signature("send", NULL, CHAN, ANY),
+	"recv":       signature("recv", ANY, CHAN),
+	"close":      signature("close", NULL, CHAN),
+	"implements": signature("implements", BOOL, ANY, ANY),
+	"print":      {Name: "print", Return: NULL, Variadic: true},
+}
+
+// methods are the signatures of the methods of basic types, without the
+// receiver.
+var methods = map[Type]map[string]*Function{
+	STR: {
+		"upper":       signature("upper", STR),
+		"lower":       signature("lower", STR),
+		"trim":        signature("trim", STR),
+		"contains":    signature("contains", BOOL, STR),
+		"starts_with": signature("starts_with", BOOL, STR),
+		"ends_with":   signature("ends_with", BOOL, STR),
+		"replace":     signature("replace", STR, STR, STR),
+		"split":       signature("split", ARRAY, STR),
+	},
+	ARRAY: {
+		"map":      signature("map", ARRAY, FN),
+		"filter":   signature("filter", ARRAY, FN),
+		"reduce":   signature("reduce", ANY, FN, ANY),
+		"contains": signature("contains", BOOL, ANY),
+		"reverse":  signature("reverse", ARRAY),
+		"sort":     signature("sort", ARRAY),
+		"join":     signature("join", STR, STR),
+	},
+}
@@ -0,0 +1,851 @@
+package checker
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vita-dounai/Firework/ast"
+)
+
+// Checker infers the types of a macro expanded program before it is
+// evaluated. Annotated names and functions are checked against their
+// annotations, everything which can not be inferred has type ANY and is never
+// reported.
+type Checker struct {
+	errors     []string
+	scope      *scope
+	signatures map[*ast.FunctionLiteral]*Function
+	// Names assigned or declared anywhere in the program, a function may read
+	// them before they are assigned
+	assigned map[string]bool
+	// Where the node being checked is, errors are reported there
+	pos ast.Position
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		signatures: map[*ast.FunctionLiteral]*Function{},
+		assigned:   map[string]bool{},
+	}
+}
+
+func (c *Checker) Errors() []string {
+	return c.errors
+}
+
+func (c *Checker) errorf(format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if c.pos.Line != 0 {
+		message = fmt.Sprintf("%d:%d: %s", c.pos.Line, c.pos.Column, message)
+	}
+	c.errors = append(c.errors, message)
+}
+
+// at moves to position until the returned function is called, nodes made by
+// macros are reported at the node enclosing them.
+func (c *Checker) at(position ast.Position) func() {
+	outer := c.pos
+	if position.Line != 0 {
+		c.pos = position
+	}
+	return func() { c.pos = outer }
+}
+
+func (c *Checker) Check(program *ast.Program) {
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		for _, name := range declaredNames(node) {
+			c.assigned[name] = true
+		}
+		return node
+	})
+
+	c.scope = newScope(nil, nil)
+	c.checkStatements(program.Statements)
+}
+
+func declaredNames(node ast.Node) []string {
+	switch node := node.(type) {
+	case *ast.AssignStatement:
+		return []string{node.Name.Value}
+	case *ast.FunctionStatement:
+		return []string{node.Function.Name}
+	case *ast.StructStatement:
+		return []string{node.Name.Value}
+	case *ast.TraitStatement:
+		return []string{node.Name.Value}
+	case *ast.ImportStatement:
+		if node.Names == nil {
+			return []string{moduleName(node.Path)}
+		}
+
+		names := []string{}
+		for _, name := range node.Names {
+			names = append(names, name.Value)
+		}
+		return names
+	default:
+		return nil
+	}
+}
+
+func moduleName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// checkBranches checks node, which is a branch or a loop, with check. The
+// variables it may or may not assign are widened to ANY before and after.
+func (c *Checker) checkBranches(node ast.Node, check func()) {
+	c.widen(node)
+	check()
+	c.widen(node)
+}
+
+func (c *Checker) widen(node ast.Node) {
+	ast.Modify(node, func(node ast.Node) ast.Node {
+		if as, ok := node.(*ast.AssignStatement); ok {
+			if b, _ := c.scope.lookup(as.Name.Value); b != nil && !b.annotated {
+				b.typ = ANY
+			}
+		}
+		return node
+	})
+}
+
+func (c *Checker) resolveAnnotation(annotation *ast.Identifier) Type {
+	if t, ok := annotations[annotation.Value]; ok {
+		return t
+	}
+
+	if b, _ := c.scope.lookup(annotation.Value); b != nil {
+		if st, ok := b.typ.(*StructType); ok {
+			return &Instance{Struct: st}
+		}
+	}
+
+	c.errorf("Unknown type: %s", annotation.Value)
+	return ANY
+}
+
+func (c *Checker) signature(literal *ast.FunctionLiteral) *Function {
+	sig := &Function{Name: literal.Name, Return: ANY}
+
+	for i := range literal.Parameters {
+		var t Type = ANY
+		if literal.ParameterTypes != nil && literal.ParameterTypes[i] != nil {
+			t = c.resolveAnnotation(literal.ParameterTypes[i])
+		}
+		sig.Params = append(sig.Params, t)
+	}
+
+	if literal.IsGenerator {
+		sig.Return = ITERATOR
+	} else if literal.ReturnType != nil {
+		sig.Return = c.resolveAnnotation(literal.ReturnType)
+	}
+
+	c.signatures[literal] = sig
+	return sig
+}
+
+// declare binds what the evaluator hoists, and the declarations which can be
+// used in annotations, before the statements of a scope are checked.
+func (c *Checker) declare(statements []ast.Statement) {
+	unwrapped := []ast.Statement{}
+	for _, statement := range statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			statement = export.Statement
+		}
+		unwrapped = append(unwrapped, statement)
+	}
+
+	for _, statement := range unwrapped {
+		switch statement := statement.(type) {
+		case *ast.StructStatement:
+			st := &StructType{Name: statement.Name.Value, Methods: map[string]*Function{}}
+			for _, field := range statement.Fields {
+				st.Fields = append(st.Fields, field.Value)
+			}
+			c.scope.define(st.Name, &binding{typ: st, constant: true})
+		case *ast.TraitStatement:
+			trait := &TraitType{Name: statement.Name.Value}
+			for _, method := range statement.Methods {
+				trait.Methods = append(trait.Methods, method.Value)
+			}
+			c.scope.define(trait.Name, &binding{typ: trait, constant: true})
+		}
+	}
+
+	for _, statement := range unwrapped {
+		switch statement := statement.(type) {
+		case *ast.FunctionStatement:
+			sig := c.signature(statement.Function)
+			c.scope.define(statement.Function.Name, &binding{typ: sig, constant: true})
+		case *ast.ImplStatement:
+			c.declareMethods(statement)
+		}
+	}
+}
+
+// declareMethods adds the signatures of the methods in an impl block to its
+// struct, the receiver is an instance of the struct unless annotated.
+func (c *Checker) declareMethods(is *ast.ImplStatement) {
+	b, _ := c.scope.lookup(is.Type.Value)
+	if b == nil {
+		return
+	}
+
+	st, ok := b.typ.(*StructType)
+	if !ok {
+		return
+	}
+
+	for _, literal := range is.Methods {
+		sig := c.signature(literal)
+		if len(sig.Params) > 0 && (literal.ParameterTypes == nil || literal.ParameterTypes[0] == nil) {
+			sig.Params[0] = &Instance{Struct: st}
+		}
+		st.Methods[literal.Name] = sig
+	}
+}
+
+func (c *Checker) checkStatements(statements []ast.Statement) Type {
+	c.declare(statements)
+
+	var result Type = NULL
+	for _, statement := range statements {
+		result = c.checkStatement(statement)
+	}
+
+	return result
+}
+
+// checkBlock checks a block in a new scope holding bindings and returns the
+// type of its value, nil if it always returns.
+func (c *Checker) checkBlock(block *ast.BlockStatement, bindings map[string]*binding) Type {
+	if block == nil {
+		return NULL
+	}
+
+	c.scope = newScope(c.scope, nil)
+	defer func() { c.scope = c.scope.outer }()
+
+	for name, b := range bindings {
+		c.scope.define(name, b)
+	}
+
+	return c.checkStatements(block.Statements)
+}
+
+// checkBranch checks the body of a match arm or a select case.
+func (c *Checker) checkBranch(body ast.Node, bindings map[string]*binding) Type {
+	if block, ok := body.(*ast.BlockStatement); ok {
+		return c.checkBlock(block, bindings)
+	}
+
+	c.scope = newScope(c.scope, nil)
+	defer func() { c.scope = c.scope.outer }()
+
+	for name, b := range bindings {
+		c.scope.define(name, b)
+	}
+
+	return c.infer(body.(ast.Expression))
+}
+
+func (c *Checker) checkStatement(statement ast.Statement) Type {
+	defer c.at(statement.Pos())()
+
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		return c.infer(statement.Expression)
+	case *ast.AssignStatement:
+		c.checkAssignStatement(statement)
+	case *ast.FieldAssignStatement:
+		c.checkFieldAssignStatement(statement)
+	case *ast.FunctionStatement:
+		c.checkFunction(statement.Function)
+	case *ast.ImplStatement:
+		c.checkImplStatement(statement)
+	case *ast.ExportStatement:
+		c.checkStatement(statement.Statement)
+	case *ast.ImportStatement:
+		// Modules are not checked, what they export is unknown
+		for _, name := range declaredNames(statement) {
+			c.scope.define(name, &binding{typ: ANY, constant: true})
+		}
+	case *ast.ReturnStatement:
+		c.checkReturnStatement(statement)
+		return nil
+	case *ast.BlockStatement:
+		return c.checkBlock(statement, nil)
+	case *ast.WhileStatement:
+		c.checkBranches(statement, func() {
+			c.infer(statement.Condition)
+			c.checkBlock(statement.Body, nil)
+		})
+	case *ast.ForStatement:
+		c.checkIterable(c.infer(statement.Iterable))
+		c.checkBranches(statement.Body, func() {
+			c.checkBlock(statement.Body, map[string]*binding{statement.Variable.Value: {typ: ANY}})
+		})
+	}
+
+	return ANY
+}
+
+func (c *Checker) checkAssignStatement(as *ast.AssignStatement) {
+	name := as.Name.Value
+	valueType := c.infer(as.Value)
+
+	b, crossed := c.scope.lookup(name)
+	if b == nil {
+		b = &binding{}
+		c.scope.define(name, b)
+	}
+
+	if as.Type != nil {
+		b.typ = c.resolveAnnotation(as.Type)
+		b.annotated = true
+	}
+
+	switch {
+	case b.annotated:
+		if !assignable(valueType, b.typ) {
+			c.errorf("Cannot assign %s to %s of type %s", valueType, name, b.typ)
+		}
+	case crossed || b.shared:
+		b.typ = ANY
+		b.shared = true
+	default:
+		b.typ = valueType
+		b.constant = false
+	}
+}
+
+func (c *Checker) checkFieldAssignStatement(fas *ast.FieldAssignStatement) {
+	objectType := c.infer(fas.Target.Object)
+	c.infer(fas.Value)
+
+	switch t := objectType.(type) {
+	case *Instance:
+		if !t.Struct.hasField(fas.Target.Member.Value) {
+			c.errorf("%s has no field: %s", t, fas.Target.Member.Value)
+		}
+	default:
+		if t != MAP && t != ANY {
+			c.errorf("Field assignment not support: %s", t)
+		}
+	}
+}
+
+func (c *Checker) checkReturnStatement(rs *ast.ReturnStatement) {
+	var t Type = NULL
+	if rs.ReturnValue != nil {
+		t = c.infer(rs.ReturnValue)
+	}
+
+	fn := c.scope.enclosingFunction()
+	if fn == nil {
+		return
+	}
+
+	fn.returns = join(fn.returns, t)
+	c.checkReturnType(fn, t)
+}
+
+func (c *Checker) checkReturnType(fn *function, t Type) {
+	if fn.returnType == nil || t == nil || assignable(t, fn.returnType) {
+		return
+	}
+
+	if fn.name == "" {
+		c.errorf("Return type mismatch, got=%s, want=%s", t, fn.returnType)
+	} else {
+		c.errorf("Return type mismatch in `%s`, got=%s, want=%s", fn.name, t, fn.returnType)
+	}
+}
+
+func (c *Checker) checkIterable(t Type) {
+	switch t {
+	case INT, BOOL, NULL, FN:
+		c.errorf("Object is not iterable: %s", t)
+		return
+	}
+
+	switch t.(type) {
+	case *Function, *StructType, *TraitType:
+		c.errorf("Object is not iterable: %s", t)
+	}
+}
+
+// checkFunction checks the body of a function, the return type of an
+// unannotated function is inferred from it.
+func (c *Checker) checkFunction(literal *ast.FunctionLiteral) *Function {
+	sig, ok := c.signatures[literal]
+	if !ok {
+		sig = c.signature(literal)
+	}
+
+	fn := &function{name: literal.Name}
+	if literal.ReturnType != nil && !literal.IsGenerator {
+		fn.returnType = sig.Return
+	}
+
+	c.scope = newScope(c.scope, fn)
+	for i, param := range literal.Parameters {
+		annotated := literal.ParameterTypes != nil && literal.ParameterTypes[i] != nil
+		c.scope.define(param.Value, &binding{typ: sig.Params[i], annotated: annotated})
+	}
+
+	bodyType := c.checkBlock(literal.Body, nil)
+	c.scope = c.scope.outer
+
+	if literal.IsGenerator {
+		return sig
+	}
+
+	if fn.returnType != nil {
+		c.checkReturnType(fn, bodyType)
+	} else if returns := join(fn.returns, bodyType); returns != nil {
+		sig.Return = returns
+	}
+
+	return sig
+}
+
+func (c *Checker) checkImplStatement(is *ast.ImplStatement) {
+	t := c.inferIdentifier(is.Type)
+	st, ok := t.(*StructType)
+	if !ok && t != ANY {
+		c.errorf("Cannot implement methods for %s", t)
+	}
+
+	if is.Trait != nil {
+		t := c.inferIdentifier(is.Trait)
+		trait, ok := t.(*TraitType)
+		if !ok && t != ANY {
+			c.errorf("Not a trait: %s", t)
+		}
+
+		if ok && st != nil {
+			for _, name := range trait.Methods {
+				if _, defined := st.Methods[name]; !defined {
+					c.errorf("%s does not implement %s required by %s", st.Name, name, trait.Name)
+				}
+			}
+		}
+	}
+
+	for _, literal := range is.Methods {
+		c.checkFunction(literal)
+	}
+}
+
+func (c *Checker) infer(expression ast.Expression) Type {
+	if node, ok := expression.(interface{ Pos() ast.Position }); ok {
+		defer c.at(node.Pos())()
+	}
+
+	switch node := expression.(type) {
+	case *ast.IntegerLiteral, *ast.BigIntegerLiteral:
+		return INT
+	case *ast.StringLiteral:
+		return STR
+	case *ast.InterpolatedString:
+		c.inferAll(node.Expressions)
+		return STR
+	case *ast.Boolean:
+		return BOOL
+	case *ast.Identifier:
+		return c.inferIdentifier(node)
+	case *ast.PrefixExpression:
+		return c.inferPrefixExpression(node)
+	case *ast.InfixExpression:
+		return c.inferInfixExpression(node)
+	case *ast.IfExpression:
+		c.infer(node.Condition)
+
+		var result Type
+		c.checkBranches(node, func() {
+			result = join(c.checkBlock(node.Consequence, nil), c.checkBlock(node.Alternative, nil))
+		})
+		return valueType(result)
+	case *ast.MatchExpression:
+		return c.inferMatchExpression(node)
+	case *ast.SelectExpression:
+		return c.inferSelectExpression(node)
+	case *ast.FunctionLiteral:
+		return c.checkFunction(node)
+	case *ast.CallExpression:
+		return c.inferCallExpression(node)
+	case *ast.SpawnExpression:
+		c.inferCallExpression(node.Call)
+		return CHAN
+	case *ast.ArrayLiteral:
+		c.inferAll(node.Elements)
+		return ARRAY
+	case *ast.TupleLiteral:
+		c.inferAll(node.Elements)
+		return TUPLE
+	case *ast.MapLiteral:
+		for _, pair := range node.Pairs {
+			c.infer(pair.Key)
+			c.infer(pair.Value)
+		}
+		return MAP
+	case *ast.IndexExpression:
+		return c.inferIndexExpression(node)
+	case *ast.SliceExpression:
+		return c.inferSliceExpression(node)
+	case *ast.MemberExpression:
+		return c.inferMemberExpression(node)
+	case *ast.YieldExpression:
+		if node.Value != nil {
+			c.infer(node.Value)
+		}
+		return ANY
+	case *ast.SpreadExpression:
+		c.infer(node.Value)
+		return ANY
+	default:
+		return ANY
+	}
+}
+
+// valueType is the type of a value computed by branches which may all return.
+func valueType(t Type) Type {
+	if t == nil {
+		return ANY
+	}
+	return t
+}
+
+func (c *Checker) inferAll(expressions []ast.Expression) []Type {
+	types := []Type{}
+	for _, expression := range expressions {
+		types = append(types, c.infer(expression))
+	}
+	return types
+}
+
+func (c *Checker) inferIdentifier(identifier *ast.Identifier) Type {
+	name := identifier.Value
+
+	b, crossed := c.scope.lookup(name)
+	if b == nil {
+		if builtin, ok := builtins[name]; ok {
+			return builtin
+		}
+
+		if c.scope.enclosingFunction() != nil && c.assigned[name] {
+			return ANY
+		}
+
+		c.errorf("Identifier not found: %s", name)
+		return ANY
+	}
+
+	if b.shared || crossed && !b.annotated && !b.constant {
+		return ANY
+	}
+
+	return b.typ
+}
+
+func isInstance(t Type) bool {
+	_, ok := t.(*Instance)
+	return ok
+}
+
+func (c *Checker) inferPrefixExpression(pe *ast.PrefixExpression) Type {
+	right := c.infer(pe.Right)
+	if right == ANY || isInstance(right) {
+		if pe.Operator == "!" {
+			return BOOL
+		}
+		return ANY
+	}
+
+	switch {
+	case pe.Operator == "!":
+		return BOOL
+	case right == INT:
+		return INT
+	default:
+		c.errorf("Unknown operator: %s%s", pe.Operator, right)
+		return ANY
+	}
+}
+
+func (c *Checker) inferInfixExpression(ie *ast.InfixExpression) Type {
+	left := c.infer(ie.Left)
+	right := c.infer(ie.Right)
+	operator := ie.Operator
+
+	isEquality := operator == "==" || operator == "!="
+
+	switch {
+	case left == ANY || right == ANY || isInstance(left) || isInstance(right):
+		if isEquality {
+			return BOOL
+		}
+		return ANY
+	case left == INT && right == INT:
+		switch operator {
+		case "<", "<=", ">", ">=", "==", "!=":
+			return BOOL
+		default:
+			return INT
+		}
+	case left == STR && right == STR:
+		switch operator {
+		case "+":
+			return STR
+		case "<", ">", "==", "!=":
+			return BOOL
+		}
+	case isEquality:
+		return BOOL
+	}
+
+	if left.String() != right.String() {
+		c.errorf("Type mismatch: %s %s %s, in %s", left, operator, right, ie)
+	} else {
+		c.errorf("Unknown operator: %s %s %s, in %s", left, operator, right, ie)
+	}
+	return ANY
+}
+
+func (c *Checker) inferIndexExpression(ie *ast.IndexExpression) Type {
+	left := c.infer(ie.Left)
+	c.infer(ie.Index)
+
+	switch left {
+	case STR:
+		return STR
+	case ARRAY, TUPLE, MAP, ANY:
+		return ANY
+	default:
+		c.errorf("Index operator not support: %s", left)
+		return ANY
+	}
+}
+
+func (c *Checker) inferSliceExpression(se *ast.SliceExpression) Type {
+	left := c.infer(se.Left)
+	for _, index := range []ast.Expression{se.Start, se.End, se.Step} {
+		if index != nil {
+			c.infer(index)
+		}
+	}
+
+	switch left {
+	case STR, ARRAY, TUPLE:
+		return left
+	case ANY:
+		return ANY
+	default:
+		c.errorf("Slice operator not support: %s", left)
+		return ANY
+	}
+}
+
+func (c *Checker) inferMemberExpression(me *ast.MemberExpression) Type {
+	t := c.infer(me.Object)
+	name := me.Member.Value
+
+	switch t := t.(type) {
+	case *Instance:
+		if !t.Struct.hasField(name) {
+			c.errorf("%s has no field: %s", t, name)
+		}
+		return ANY
+	default:
+		if t != MAP && t != MODULE && t != ANY {
+			c.errorf("Member access not support: %s", t)
+		}
+		return ANY
+	}
+}
+
+// patternBindings are the names bound by a match pattern.
+func patternBindings(pattern ast.Pattern, bindings map[string]*binding) {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		bindings[pattern.Name.Value] = &binding{typ: ANY}
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			patternBindings(element, bindings)
+		}
+		if pattern.Rest != nil {
+			bindings[pattern.Rest.Value] = &binding{typ: ARRAY}
+		}
+	case *ast.MapPattern:
+		for _, pair := range pattern.Pairs {
+			patternBindings(pair.Pattern, bindings)
+		}
+	}
+}
+
+func (c *Checker) inferMatchExpression(me *ast.MatchExpression) Type {
+	c.infer(me.Subject)
+
+	var result Type
+	c.checkBranches(me, func() {
+		result = c.inferMatchArms(me.Arms)
+	})
+	return valueType(result)
+}
+
+func (c *Checker) inferMatchArms(arms []*ast.MatchArm) Type {
+	var result Type
+	for _, arm := range arms {
+		bindings := map[string]*binding{}
+		patternBindings(arm.Pattern, bindings)
+
+		if arm.Guard != nil {
+			c.scope = newScope(c.scope, nil)
+			for name, b := range bindings {
+				c.scope.define(name, b)
+			}
+			c.infer(arm.Guard)
+			c.scope = c.scope.outer
+		}
+
+		result = join(result, c.checkBranch(arm.Body, bindings))
+	}
+
+	return result
+}
+
+func (c *Checker) inferSelectExpression(se *ast.SelectExpression) Type {
+	var result Type
+	c.checkBranches(se, func() {
+		result = c.inferSelectCases(se.Cases)
+	})
+	return valueType(result)
+}
+
+func (c *Checker) inferSelectCases(cases []*ast.SelectCase) Type {
+	var result Type
+	for _, sc := range cases {
+		bindings := map[string]*binding{}
+		if sc.Call != nil {
+			c.inferCallExpression(sc.Call)
+		}
+		if sc.Name != nil {
+			bindings[sc.Name.Value] = &binding{typ: ANY}
+		}
+
+		result = join(result, c.checkBranch(sc.Body, bindings))
+	}
+
+	return result
+}
+
+func (c *Checker) inferCallExpression(ce *ast.CallExpression) Type {
+	if identifier, ok := ce.Function.(*ast.Identifier); ok && identifier.Value == "quote" {
+		return ANY
+	}
+
+	if member, ok := ce.Function.(*ast.MemberExpression); ok {
+		return c.inferMethodCall(member, ce.Arguments)
+	}
+
+	callee := c.infer(ce.Function)
+	return c.apply(callee, c.inferAll(ce.Arguments), hasSpread(ce.Arguments))
+}
+
+func hasSpread(arguments []ast.Expression) bool {
+	for _, argument := range arguments {
+		if _, ok := argument.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inferMethodCall mirrors the resolution of receiver.name(args) by the
+// evaluator.
+func (c *Checker) inferMethodCall(me *ast.MemberExpression, arguments []ast.Expression) Type {
+	receiver := c.infer(me.Object)
+	args := c.inferAll(arguments)
+	spread := hasSpread(arguments)
+	name := me.Member.Value
+
+	switch r := receiver.(type) {
+	case *Instance:
+		if r.Struct.hasField(name) {
+			return ANY
+		}
+
+		if method, ok := r.Struct.Methods[name]; ok {
+			return c.apply(method, append([]Type{r}, args...), spread)
+		}
+	default:
+		if r == ANY || r == MAP || r == MODULE {
+			return ANY
+		}
+
+		if method, ok := methods[r][name]; ok {
+			return c.apply(method, args, spread)
+		}
+	}
+
+	if b, _ := c.scope.lookup(name); b != nil {
+		return c.apply(c.inferIdentifier(&ast.Identifier{Value: name}), append([]Type{receiver}, args...), spread)
+	}
+
+	if builtin, ok := builtins[name]; ok {
+		return c.apply(builtin, append([]Type{receiver}, args...), spread)
+	}
+
+	c.errorf("%s has no method: %s", receiver, name)
+	return ANY
+}
+
+// apply checks a call of callee with arguments of types args, which are
+// unknown in number if some are spread.
+func (c *Checker) apply(callee Type, args []Type, spread bool) Type {
+	switch f := callee.(type) {
+	case *Function:
+		if f.Variadic || spread {
+			return f.Return
+		}
+
+		if len(args) != len(f.Params) {
+			if f.Name == "" {
+				c.errorf("Wrong number of arguments, got=%d, want=%d", len(args), len(f.Params))
+			} else {
+				c.errorf("Wrong number of arguments to `%s`, got=%d, want=%d", f.Name, len(args), len(f.Params))
+			}
+			return f.Return
+		}
+
+		for i, arg := range args {
+			if assignable(arg, f.Params[i]) {
+				continue
+			}
+
+			if f.Name == "" {
+				c.errorf("Argument %d must be %s, got %s", i+1, f.Params[i], arg)
+			} else {
+				c.errorf("Argument %d to `%s` must be %s, got %s", i+1, f.Name, f.Params[i], arg)
+			}
+		}
+
+		return f.Return
+	case *StructType:
+		if !spread && len(args) != len(f.Fields) {
+			c.errorf("Wrong number of arguments to `%s`, got=%d, want=%d", f.Name, len(args), len(f.Fields))
+		}
+		return &Instance{Struct: f}
+	default:
+		if callee != ANY && callee != FN {
+			c.errorf("Not a function: %s", callee)
+		}
+		return ANY
+	}
+}
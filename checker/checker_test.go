@@ -0,0 +1,105 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vita-dounai/Firework/lexer"
+	"github.com/vita-dounai/Firework/parser"
+)
+
+func checkProgram(t *testing.T, input string) []string {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors for %q: %v", input, p.Errors())
+	}
+
+	c := NewChecker()
+	c.Check(program)
+	return c.Errors()
+}
+
+func TestWellTypedPrograms(t *testing.T) {
+	tests := []string{
+		"fn add(a: int, b: int) -> int { a + b }; add(1, 2) * 3",
+		"greet = |name: str| -> str { \"hi \" + name }; greet(\"bob\").upper()",
+		"x: int = 1; x = x + 1",
+		"fn fact(n) { if n == 0 { 1 } else { n * fact(n - 1) } }; fact(5) + 1",
+		"fn f() { count = count + 1 }; count = 0; f()",
+		"x = 1\nif true { x = \"a\" }\nx + 1",
+		"x = 1; while x < 3 { x = \"a\" }",
+		"struct Point { x, y }; impl Point { fn norm(self) -> int { self.x * self.x } }; Point(1, 2).norm()",
+		"struct P { x }; fn get(p: P) { p.x }; get(P(1))",
+		"fn apply(f: fn, x) { f(x) }; apply(|x| { x }, 1)",
+		"fn twice(x) { [x, x] }; twice(1).map(|x| { x + 1 }).join(\",\")",
+		"match [1, [2, 3]] { [a, [b, c]] => a + b + c, _ => 0 }",
+		"fn gen() { yield 1 }; for x in gen() { print(x) }",
+		"fn early(x) -> str { if x { return \"a\" }; \"b\" }",
+		"fn f(a) { a }; args = [1]; f(...args)",
+		"c = chan(1); send(c, 1); recv(c) + 1",
+		"fn inc(x) { x + 1 }; 1.inc()",
+		"fn nat() { n = 0\nwhile true { yield n\nn = n + 1 } }\nnext(take(nat(), 1))",
+		"fn nat() { n = 0\nwhile true { yield n\nn = n + 1 } }\nx: iterator = take(nat(), 1)",
+		"import \"lib/util\"\nfrom \"lib/util\" import add\nprint(add(1, 2), util.add(3, 4), util[\"add\"](5, 6))",
+		"from \"lib/util\" import add\nfn f() { add(1) }",
+	}
+
+	for _, input := range tests {
+		if errors := checkProgram(t, input); len(errors) != 0 {
+			t.Errorf("unexpected errors for %q: %v", input, errors)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + \"a\"", []string{"1:3: Type mismatch: int + str, in (1 + \"a\")"}},
+		{"\"a\" - \"b\"", []string{"1:5: Unknown operator: str - str, in (\"a\" - \"b\")"}},
+		{"-true", []string{"1:1: Unknown operator: -bool"}},
+		{"x = 1; x + true", []string{"1:10: Type mismatch: int + bool, in (x + true)"}},
+		{"foobar", []string{"1:1: Identifier not found: foobar"}},
+		{"add(1)\nfrom \"lib/util\" import add", []string{"1:1: Identifier not found: add"}},
+		{"fn f() { missing }", []string{"1:10: Identifier not found: missing"}},
+		{"x: int = \"a\"", []string{"1:1: Cannot assign str to x of type int"}},
+		{"x: int = 1; x = true", []string{"1:13: Cannot assign bool to x of type int"}},
+		{"x: Nope = 1", []string{"1:1: Unknown type: Nope"}},
+		{"fn add(a: int, b: int) { a + b }; add(1)",
+			[]string{"1:38: Wrong number of arguments to `add`, got=1, want=2"}},
+		{"fn add(a: int, b: int) { a + b }; add(1, \"b\")",
+			[]string{"1:38: Argument 2 to `add` must be int, got str"}},
+		{"|x| { x }(1, 2)", []string{"1:10: Wrong number of arguments, got=2, want=1"}},
+		{"len(1, 2)", []string{"1:4: Wrong number of arguments to `len`, got=2, want=1"}},
+		{"recv(1)", []string{"1:5: Argument 1 to `recv` must be chan, got int"}},
+		{"fn f(a: str) -> int { a }", []string{"1:1: Return type mismatch in `f`, got=str, want=int"}},
+		{"fn f(a) -> int { return \"a\" }", []string{"1:18: Return type mismatch in `f`, got=str, want=int"}},
+		{"fn f() -> int { 1 }; f() + \"a\"", []string{"1:26: Type mismatch: int + str, in (f() + \"a\")"}},
+		{"fn f() { \"a\" }; f() - 1", []string{"1:21: Type mismatch: str - int, in (f() - 1)"}},
+		{"1(2)", []string{"1:2: Not a function: int"}},
+		{"\"a\".upper(1)", []string{"1:10: Wrong number of arguments to `upper`, got=1, want=0"}},
+		{"\"a\".foo()", []string{"1:8: str has no method: foo"}},
+		{"1.x", []string{"1:2: Member access not support: int"}},
+		{"for x in 1 { x }", []string{"1:1: Object is not iterable: int"}},
+		{"struct P { x }; P(1, 2)", []string{"1:18: Wrong number of arguments to `P`, got=2, want=1"}},
+		{"struct P { x }; p = P(1); p.y", []string{"1:28: P has no field: y"}},
+		{"struct P { x }; p = P(1); p.y = 2", []string{"1:27: P has no field: y"}},
+		{"struct P { x }; fn f(p: P) { p.norm() }", []string{"1:36: P has no method: norm"}},
+		{"struct P { x }; fn f(p: P) {}; f(1)", []string{"1:33: Argument 1 to `f` must be P, got int"}},
+		{"struct S {}; trait T { area }; impl T for S {}", []string{"1:32: S does not implement area required by T"}},
+		{"x = 1\nif x > 0 {\n    x + \"a\"\n}", []string{"3:7: Type mismatch: int + str, in (x + \"a\")"}},
+		{"x = 1; impl x {}", []string{"1:8: Cannot implement methods for int"}},
+	}
+
+	for _, tt := range tests {
+		errors := checkProgram(t, tt.input)
+		if !reflect.DeepEqual(errors, tt.expected) {
+			t.Errorf("wrong errors for %q, expected=%v, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package checker
+
+type binding struct {
+	typ       Type
+	annotated bool
+	// Declarations and imports, which keep their type in functions
+	constant bool
+	// Assigned from a nested function, so its type is unknown anywhere
+	shared bool
+}
+
+// function is what the checker knows of the function being checked.
+type function struct {
+	name       string
+	returnType Type // Nil unless annotated
+	returns    Type // Join of the returned values
+}
+
+// scope mirrors the environments created by the evaluator.
+type scope struct {
+	bindings map[string]*binding
+	outer    *scope
+	function *function // Non-nil for the scope of parameters
+}
+
+func newScope(outer *scope, fn *function) *scope {
+	return &scope{bindings: map[string]*binding{}, outer: outer, function: fn}
+}
+
+func (s *scope) define(name string, b *binding) {
+	s.bindings[name] = b
+}
+
+// lookup returns the binding of name and whether it belongs to a scope outside
+// the innermost function.
+func (s *scope) lookup(name string) (*binding, bool) {
+	crossed := false
+	for current := s; current != nil; current = current.outer {
+		if b, ok := current.bindings[name]; ok {
+			return b, crossed
+		}
+
+		if current.function != nil {
+			crossed = true
+		}
+	}
+	return nil, false
+}
+
+func (s *scope) enclosingFunction() *function {
+	for current := s; current != nil; current = current.outer {
+		if current.function != nil {
+			return current.function
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package checker
+
+import (
+	"strings"
+)
+
+// Type is the static type of an expression.
+type Type interface {
+	String() string
+}
+
+// Basic types are named by their annotation.
+type Basic string
+
+func (b Basic) String() string { return string(b) }
+
+const (
+	INT      = Basic("int")
+	STR      = Basic("str")
+	BOOL     = Basic("bool")
+	NULL     = Basic("null")
+	ARRAY    = Basic("array")
+	TUPLE    = Basic("tuple")
+	MAP      = Basic("map")
+	FN       = Basic("fn") // Any function
+	CHAN     = Basic("chan")
+	ITERATOR = Basic("iterator")
+	MODULE   = Basic("module")
+	// ANY is given to everything that can not be inferred, it is compatible
+	// with all types.
+	ANY = Basic("any")
+)
+
+// annotations are the basic types which can be written in annotations.
+var annotations = map[string]Type{
+	"int":      INT,
+	"str":      STR,
+	"bool":     BOOL,
+	"null":     NULL,
+	"array":    ARRAY,
+	"tuple":    TUPLE,
+	"map":      MAP,
+	"fn":       FN,
+	"chan":     CHAN,
+	"iterator": ITERATOR,
+	"any":      ANY,
+}
+
+// Function is the type of a function with known parameters.
+type Function struct {
+	Name     string
+	Params   []Type
+	Return   Type
+	Variadic bool // Arguments are not checked
+}
+
+func (f *Function) String() string {
+	params := []string{}
+	for _, param := range f.Params {
+		params = append(params, param.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// StructType is the type of a struct declaration, calling it constructs an
+// Instance.
+type StructType struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function
+}
+
+func (st *StructType) String() string { return "struct " + st.Name }
+
+func (st *StructType) hasField(name string) bool {
+	for _, field := range st.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Instance is the type of the values constructed by a struct.
+type Instance struct {
+	Struct *StructType
+}
+
+func (i *Instance) String() string { return i.Struct.Name }
+
+// TraitType is the type of a trait declaration.
+type TraitType struct {
+	Name    string
+	Methods []string
+}
+
+func (tt *TraitType) String() string { return "trait " + tt.Name }
+
+// assignable reports whether a value of type from can be used where to is
+// expected.
+func assignable(from, to Type) bool {
+	if from == ANY || to == ANY {
+		return true
+	}
+
+	switch to := to.(type) {
+	case *Function:
+		return from == FN || isFunction(from)
+	case *Instance:
+		instance, ok := from.(*Instance)
+		return ok && instance.Struct == to.Struct
+	default:
+		if to == FN {
+			return from == FN || isFunction(from)
+		}
+		return from == to
+	}
+}
+
+func isFunction(t Type) bool {
+	switch t.(type) {
+	case *Function, *StructType:
+		return true
+	default:
+		return false
+	}
+}
+
+// join is the type of a value which is either a or b, nil stands for no
+// value.
+func join(a, b Type) Type {
+	if a == nil {
+		return b
+	}
+
+	if b == nil {
+		return a
+	}
+
+	if assignable(a, b) && assignable(b, a) && a != ANY && b != ANY {
+		return a
+	}
+
+	return ANY
+}
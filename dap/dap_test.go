@@ -0,0 +1,326 @@
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `fn add(a, b) {
+    c = a + b
+    c
+}
+nums = [1, 2]
+y = add(nums[0], 2)
+print(y)
+`
+
+type message map[string]interface{}
+
+func (m message) body() message {
+	body, _ := m["body"].(map[string]interface{})
+	return body
+}
+
+func (m message) list(key string) []message {
+	items, _ := m[key].([]interface{})
+	list := []message{}
+	for _, item := range items {
+		list = append(list, item.(map[string]interface{}))
+	}
+	return list
+}
+
+// client scripts a session the way an editor would.
+type client struct {
+	t        *testing.T
+	in       io.Writer
+	seq      int
+	messages chan message
+	events   []message
+}
+
+func newClient(t *testing.T) (*client, chan error) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{t: t, in: clientOut, messages: make(chan message, 100)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			content, err := ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+
+			m := message{}
+			json.Unmarshal(content, &m)
+			c.messages <- m
+		}
+	}()
+
+	return c, done
+}
+
+func (c *client) next() message {
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("the server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+		return nil
+	}
+}
+
+// request sends a request and returns its response, events received meanwhile
+// are kept for event.
+func (c *client) request(command string, arguments interface{}) message {
+	c.seq++
+	if err := WriteMessage(c.in, &Request{Seq: c.seq, Type: "request", Command: command, Arguments: encode(arguments)}); err != nil {
+		c.t.Fatalf("sending %s failed: %s", command, err)
+	}
+
+	for {
+		m := c.next()
+		if m["type"] == "response" && m["request_seq"] == float64(c.seq) {
+			if m["command"] != command {
+				c.t.Fatalf("response to %s is for %v", command, m["command"])
+			}
+			return m
+		}
+		c.events = append(c.events, m)
+	}
+}
+
+// success sends a request which must succeed, and returns its body.
+func (c *client) success(command string, arguments interface{}) message {
+	response := c.request(command, arguments)
+	if response["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, response["message"])
+	}
+	return response.body()
+}
+
+// event returns the next event named name, skipping the others.
+func (c *client) event(name string) message {
+	for {
+		var m message
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.next()
+		}
+
+		if m["type"] == "event" && m["event"] == name {
+			return m.body()
+		}
+	}
+}
+
+func encode(arguments interface{}) json.RawMessage {
+	if arguments == nil {
+		return nil
+	}
+	content, _ := json.Marshal(arguments)
+	return content
+}
+
+func variables(c *client, reference interface{}) map[string]message {
+	body := c.success("variables", message{"variablesReference": reference})
+	variables := map[string]message{}
+	for _, variable := range body.list("variables") {
+		variables[variable["name"].(string)] = variable
+	}
+	return variables
+}
+
+func writeProgram(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "main.fw")
+	if err := ioutil.WriteFile(path, []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestReadMessage(t *testing.T) {
+	var buffer bytes.Buffer
+	WriteMessage(&buffer, message{"seq": 1})
+	buffer.WriteString("Content-Type: application/json\r\nContent-Length: 2\r\n\r\n{}")
+	buffer.WriteString("Content-Length: x\r\n\r\n")
+
+	r := bufio.NewReader(&buffer)
+	for _, expected := range []string{`{"seq":1}`, "{}"} {
+		content, err := ReadMessage(r)
+		if err != nil || string(content) != expected {
+			t.Errorf("wrong message, expected=%q, got=%q (%v)", expected, content, err)
+		}
+	}
+
+	if _, err := ReadMessage(r); err == nil || err.Error() != `Malformed header: "Content-Length: x"` {
+		t.Errorf("expected a malformed header, got %v", err)
+	}
+
+	if _, err := ReadMessage(bufio.NewReader(strings.NewReader("\r\n"))); err == nil || err.Error() != "Missing header: Content-Length" {
+		t.Errorf("expected a missing header, got %v", err)
+	}
+}
+
+func TestSession(t *testing.T) {
+	path, cleanup := writeProgram(t)
+	defer cleanup()
+
+	c, done := newClient(t)
+
+	capabilities := c.success("initialize", message{"adapterID": "firework"})
+	if capabilities["supportsFunctionBreakpoints"] != true {
+		t.Errorf("wrong capabilities: %v", capabilities)
+	}
+	c.event("initialized")
+
+	if response := c.request("launch", message{}); response["success"] != false || response["message"] != "No program to launch" {
+		t.Errorf("launch without a program succeeded: %v", response)
+	}
+	c.success("launch", message{"program": path, "stopOnEntry": true})
+
+	breakpoints := c.success("setBreakpoints", message{
+		"source":      message{"path": path},
+		"breakpoints": []message{{"line": 3}},
+	}).list("breakpoints")
+	if len(breakpoints) != 1 || breakpoints[0]["verified"] != true || breakpoints[0]["line"] != float64(3) {
+		t.Errorf("wrong breakpoints: %v", breakpoints)
+	}
+	c.success("configurationDone", nil)
+
+	if stopped := c.event("stopped"); stopped["reason"] != "entry" {
+		t.Errorf("expected to stop on entry, got %v", stopped)
+	}
+
+	c.success("continue", message{"threadId": THREAD_ID})
+	if stopped := c.event("stopped"); stopped["reason"] != "breakpoint" {
+		t.Errorf("expected to stop at the breakpoint, got %v", stopped)
+	}
+
+	frames := c.success("stackTrace", message{"threadId": THREAD_ID}).list("stackFrames")
+	if len(frames) != 2 || frames[0]["name"] != "add" || frames[0]["line"] != float64(3) ||
+		frames[1]["name"] != "<module>" || frames[1]["line"] != float64(6) {
+		t.Fatalf("wrong stack frames: %v", frames)
+	}
+
+	scopes := c.success("scopes", message{"frameId": frames[0]["id"]}).list("scopes")
+	if len(scopes) != 2 || scopes[0]["name"] != "Locals" || scopes[1]["name"] != "Globals" {
+		t.Fatalf("wrong scopes: %v", scopes)
+	}
+
+	locals := variables(c, scopes[0]["variablesReference"])
+	for name, value := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		if locals[name]["value"] != value {
+			t.Errorf("wrong local %s: %v", name, locals[name])
+		}
+	}
+
+	nums := variables(c, scopes[1]["variablesReference"])["nums"]
+	if nums["value"] != "[1, 2]" || nums["type"] != "ARRAY" {
+		t.Fatalf("wrong global nums: %v", nums)
+	}
+	if elements := variables(c, nums["variablesReference"]); elements["[1]"]["value"] != "2" {
+		t.Errorf("wrong elements of nums: %v", elements)
+	}
+
+	if result := c.success("evaluate", message{"expression": "c * 10 + nums[1]", "frameId": frames[0]["id"]}); result["result"] != "32" {
+		t.Errorf("wrong result: %v", result)
+	}
+	if response := c.request("evaluate", message{"expression": "d", "frameId": frames[0]["id"]}); response["success"] != false || response["message"] != "Identifier not found: d" {
+		t.Errorf("expected evaluate to fail: %v", response)
+	}
+
+	c.success("next", message{"threadId": THREAD_ID})
+	if stopped := c.event("stopped"); stopped["reason"] != "step" {
+		t.Errorf("expected to stop after a step, got %v", stopped)
+	}
+	if frames := c.success("stackTrace", message{"threadId": THREAD_ID}).list("stackFrames"); frames[0]["line"] != float64(7) {
+		t.Errorf("stepped to the wrong line: %v", frames)
+	}
+
+	c.success("continue", message{"threadId": THREAD_ID})
+	if output := c.event("output"); output["output"] != "3" {
+		t.Errorf("wrong output: %v", output)
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(0) {
+		t.Errorf("wrong exit code: %v", exited)
+	}
+	c.event("terminated")
+
+	if response := c.request("next", message{"threadId": THREAD_ID}); response["success"] != false {
+		t.Errorf("stepped a program which is not paused")
+	}
+	if response := c.request("attach", nil); response["message"] != "Unsupported command: attach" {
+		t.Errorf("expected attach to be unsupported: %v", response)
+	}
+
+	c.success("disconnect", nil)
+	if err := <-done; err != nil {
+		t.Errorf("the server failed: %s", err)
+	}
+}
+
+func TestFunctionBreakpointsAndErrors(t *testing.T) {
+	path, cleanup := writeProgram(t)
+	defer cleanup()
+	ioutil.WriteFile(path, []byte(program+"add(1, \"a\")\n"), 0644)
+
+	c, done := newClient(t)
+	c.success("initialize", nil)
+	c.success("setFunctionBreakpoints", message{"breakpoints": []message{{"name": "add"}}})
+	c.success("launch", message{"program": path})
+	c.success("configurationDone", nil)
+
+	if stopped := c.event("stopped"); stopped["reason"] != "function breakpoint" {
+		t.Errorf("expected to stop in add, got %v", stopped)
+	}
+	c.success("setFunctionBreakpoints", message{"breakpoints": []message{}})
+	c.success("stepOut", message{"threadId": THREAD_ID})
+
+	if stopped := c.event("stopped"); stopped["reason"] != "step" {
+		t.Errorf("expected to step out of add, got %v", stopped)
+	}
+	c.success("continue", message{"threadId": THREAD_ID})
+
+	for {
+		output := c.event("output")
+		if output["category"] == "stderr" {
+			if !strings.Contains(output["output"].(string), "INTEGER + STRING") {
+				t.Errorf("wrong error: %v", output)
+			}
+			break
+		}
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(1) {
+		t.Errorf("wrong exit code: %v", exited)
+	}
+
+	c.success("disconnect", nil)
+	<-done
+}
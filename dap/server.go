@@ -0,0 +1,487 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/vita-dounai/Firework/debugger"
+	"github.com/vita-dounai/Firework/evaluator"
+	"github.com/vita-dounai/Firework/object"
+)
+
+// THREAD_ID identifies the only thread reported, spawned tasks are not told
+// apart by the debugger.
+const THREAD_ID = 1
+
+// Server is a debug adapter, it runs a Firework program under the debugger
+// for a client such as an editor.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	debugger *debugger.Debugger
+
+	// Guards the fields below and the writes to out
+	mu  sync.Mutex
+	seq int
+
+	program     string
+	stopOnEntry bool
+	noDebug     bool
+	launched    bool
+	configured  bool
+	running     bool
+
+	// Set while the program is paused, references are only valid until it
+	// resumes
+	stopped    bool
+	stack      []debugger.Frame
+	references []interface{}
+
+	resume chan struct{}
+	done   chan struct{}
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		debugger: debugger.NewDebugger(),
+		resume:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.debugger.Paused = s.paused
+	return s
+}
+
+// Serve handles requests until the client disconnects or in is exhausted.
+// The output of the program is sent as output events meanwhile.
+func (s *Server) Serve() error {
+	output := evaluator.Output
+	evaluator.Output = &outputWriter{server: s, category: "stdout"}
+	defer func() {
+		evaluator.Output = output
+		close(s.done)
+	}()
+
+	for {
+		content, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		request := &Request{}
+		if err := json.Unmarshal(content, request); err != nil {
+			return fmt.Errorf("Malformed message: %s", err)
+		}
+
+		if request.Type != "request" {
+			continue
+		}
+
+		if !s.handle(request) {
+			return nil
+		}
+	}
+}
+
+func (s *Server) send(message interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	switch message := message.(type) {
+	case *Response:
+		message.Seq = s.seq
+	case *Event:
+		message.Seq = s.seq
+	}
+
+	WriteMessage(s.out, message)
+}
+
+func (s *Server) respond(request *Request, body interface{}) {
+	s.send(&Response{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Success:    true,
+		Command:    request.Command,
+		Body:       body,
+	})
+}
+
+func (s *Server) fail(request *Request, format string, a ...interface{}) {
+	s.send(&Response{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Success:    false,
+		Command:    request.Command,
+		Message:    fmt.Sprintf(format, a...),
+	})
+}
+
+func (s *Server) event(event string, body interface{}) {
+	s.send(&Event{Type: "event", Event: event, Body: body})
+}
+
+// handle answers request, it returns false once the session is over.
+func (s *Server) handle(request *Request) bool {
+	switch request.Command {
+	case "initialize":
+		s.respond(request, &Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		s.launch(request)
+	case "setBreakpoints":
+		s.setBreakpoints(request)
+	case "setFunctionBreakpoints":
+		s.setFunctionBreakpoints(request)
+	case "setExceptionBreakpoints":
+		s.respond(request, map[string]interface{}{"breakpoints": []Breakpoint{}})
+	case "configurationDone":
+		s.respond(request, nil)
+		s.mu.Lock()
+		s.configured = true
+		s.mu.Unlock()
+		s.start()
+	case "threads":
+		s.respond(request, map[string]interface{}{
+			"threads": []Thread{{ID: THREAD_ID, Name: "main"}},
+		})
+	case "stackTrace":
+		s.stackTrace(request)
+	case "scopes":
+		s.scopes(request)
+	case "variables":
+		s.variables(request)
+	case "evaluate":
+		s.evaluate(request)
+	case "continue":
+		s.step(request, s.debugger.Continue)
+	case "next":
+		s.step(request, s.debugger.StepOver)
+	case "stepIn":
+		s.step(request, s.debugger.StepInto)
+	case "stepOut":
+		s.step(request, s.debugger.StepOut)
+	case "disconnect", "terminate":
+		s.respond(request, nil)
+		s.debugger.SetBreakpoints("", nil)
+		s.debugger.SetFunctionBreakpoints(nil)
+		return false
+	default:
+		s.fail(request, "Unsupported command: %s", request.Command)
+	}
+
+	return true
+}
+
+// arguments decodes the arguments of request into v, failing the request if
+// they are malformed.
+func (s *Server) arguments(request *Request, v interface{}) bool {
+	if len(request.Arguments) == 0 {
+		return true
+	}
+
+	if err := json.Unmarshal(request.Arguments, v); err != nil {
+		s.fail(request, "Malformed arguments: %s", err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) launch(request *Request) {
+	args := &LaunchArguments{}
+	if !s.arguments(request, args) {
+		return
+	}
+
+	if args.Program == "" {
+		s.fail(request, "No program to launch")
+		return
+	}
+
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		s.fail(request, "%s", err)
+		return
+	}
+
+	if _, err := os.Stat(program); err != nil {
+		s.fail(request, "%s", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.program = program
+	s.stopOnEntry = args.StopOnEntry
+	s.noDebug = args.NoDebug
+	s.launched = true
+	s.mu.Unlock()
+
+	s.respond(request, nil)
+	s.start()
+}
+
+// start runs the program once it is launched and configured.
+func (s *Server) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.launched || !s.configured || s.running {
+		return
+	}
+	s.running = true
+
+	if !s.noDebug {
+		s.debugger.Start(s.stopOnEntry)
+		evaluator.SetHook(s.debugger)
+	}
+
+	go s.run(s.program)
+}
+
+func (s *Server) run(program string) {
+	_, err := evaluator.RunModule(program)
+	evaluator.SetHook(nil)
+
+	exitCode := 0
+	if err != nil {
+		s.event("output", map[string]interface{}{"category": "stderr", "output": err.Inspect() + "\n"})
+		exitCode = 1
+	}
+
+	s.event("exited", map[string]interface{}{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// paused reports a stop to the client and waits for it to resume the
+// program.
+func (s *Server) paused(reason string) {
+	s.mu.Lock()
+	s.stopped = true
+	s.stack = s.debugger.Stack()
+	s.references = nil
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          THREAD_ID,
+		"allThreadsStopped": true,
+	})
+
+	select {
+	case <-s.resume:
+	case <-s.done:
+		s.debugger.Continue()
+	}
+}
+
+// step resumes the paused program after calling resume, which tells the
+// debugger where to stop next.
+func (s *Server) step(request *Request, resume func()) {
+	s.mu.Lock()
+	stopped := s.stopped
+	s.stopped = false
+	s.stack = nil
+	s.references = nil
+	s.mu.Unlock()
+
+	if !stopped {
+		s.fail(request, "The program is not paused")
+		return
+	}
+
+	resume()
+	if request.Command == "continue" {
+		s.respond(request, map[string]interface{}{"allThreadsContinued": true})
+	} else {
+		s.respond(request, nil)
+	}
+	s.resume <- struct{}{}
+}
+
+func (s *Server) setBreakpoints(request *Request) {
+	args := &SetBreakpointsArguments{}
+	if !s.arguments(request, args) {
+		return
+	}
+
+	path := filepath.Clean(args.Source.Path)
+	lines := []int{}
+	breakpoints := []Breakpoint{}
+	for _, breakpoint := range args.Breakpoints {
+		lines = append(lines, breakpoint.Line)
+		breakpoints = append(breakpoints, Breakpoint{
+			Verified: true,
+			Line:     breakpoint.Line,
+			Source:   &Source{Name: filepath.Base(path), Path: path},
+		})
+	}
+
+	s.debugger.SetBreakpoints(path, lines)
+	s.respond(request, map[string]interface{}{"breakpoints": breakpoints})
+}
+
+func (s *Server) setFunctionBreakpoints(request *Request) {
+	args := &SetFunctionBreakpointsArguments{}
+	if !s.arguments(request, args) {
+		return
+	}
+
+	names := []string{}
+	breakpoints := []Breakpoint{}
+	for _, breakpoint := range args.Breakpoints {
+		names = append(names, breakpoint.Name)
+		breakpoints = append(breakpoints, Breakpoint{Verified: true})
+	}
+
+	s.debugger.SetFunctionBreakpoints(names)
+	s.respond(request, map[string]interface{}{"breakpoints": breakpoints})
+}
+
+func (s *Server) stackTrace(request *Request) {
+	args := &StackTraceArguments{}
+	if !s.arguments(request, args) {
+		return
+	}
+
+	s.mu.Lock()
+	stack := s.stack
+	s.mu.Unlock()
+
+	frames := []StackFrame{}
+	for i, frame := range stack {
+		if i < args.StartFrame || args.Levels > 0 && len(frames) == args.Levels {
+			continue
+		}
+
+		stackFrame := StackFrame{ID: i + 1, Name: frame.Name, Line: frame.Line, Column: 1}
+		if frame.Path != "" {
+			stackFrame.Source = &Source{Name: filepath.Base(frame.Path), Path: frame.Path}
+		}
+		frames = append(frames, stackFrame)
+	}
+
+	s.respond(request, map[string]interface{}{
+		"stackFrames": frames,
+		"totalFrames": len(stack),
+	})
+}
+
+// frame returns the paused frame id, the innermost one for id 0.
+func (s *Server) frame(id int) (debugger.Frame, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == 0 && len(s.stack) > 0 {
+		id = 1
+	}
+
+	if id < 1 || id > len(s.stack) {
+		return debugger.Frame{}, false
+	}
+	return s.stack[id-1], true
+}
+
+func (s *Server) scopes(request *Request) {
+	args := &ScopesArguments{}
+	if !s.arguments(request, args) {
+		return
+	}
+
+	frame, ok := s.frame(args.FrameID)
+	if !ok {
+		s.fail(request, "No frame %d", args.FrameID)
+		return
+	}
+
+	scopes := []Scope{}
+	locals, globals := frameScopes(frame.Env)
+	if locals != nil {
+		scopes = append(scopes, Scope{Name: "Locals", VariablesReference: s.reference(locals)})
+	}
+	if globals != nil {
+		scopes = append(scopes, Scope{Name: "Globals", VariablesReference: s.reference(globals)})
+	}
+
+	s.respond(request, map[string]interface{}{"scopes": scopes})
+}
+
+func (s *Server) variables(request *Request) {
+	args := &VariablesArguments{}
+	if !s.arguments(request, args) {
+		return
+	}
+
+	s.mu.Lock()
+	var referenced interface{}
+	if i := args.VariablesReference - 1; i >= 0 && i < len(s.references) {
+		referenced = s.references[i]
+	}
+	s.mu.Unlock()
+
+	if referenced == nil {
+		s.fail(request, "No variables for reference %d", args.VariablesReference)
+		return
+	}
+
+	variables := []Variable{}
+	for _, binding := range children(referenced) {
+		variables = append(variables, s.variable(binding.Name, binding.Value))
+	}
+
+	s.respond(request, map[string]interface{}{"variables": variables})
+}
+
+func (s *Server) evaluate(request *Request) {
+	args := &EvaluateArguments{}
+	if !s.arguments(request, args) {
+		return
+	}
+
+	frame, ok := s.frame(args.FrameID)
+	if !ok {
+		s.fail(request, "The program is not paused")
+		return
+	}
+
+	result := s.debugger.Evaluate(args.Expression, frame.Env)
+	if err, ok := result.(*object.Error); ok {
+		s.fail(request, "%s", err.Message)
+		return
+	}
+
+	body := map[string]interface{}{"result": "", "variablesReference": 0}
+	if result != nil {
+		variable := s.variable("", result)
+		body["result"] = variable.Value
+		body["type"] = variable.Type
+		body["variablesReference"] = variable.VariablesReference
+	}
+	s.respond(request, body)
+}
+
+// outputWriter sends what the program prints as output events.
+type outputWriter struct {
+	server   *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.server.event("output", map[string]interface{}{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
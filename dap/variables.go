@@ -0,0 +1,119 @@
+package dap
+
+import (
+	"fmt"
+
+	"github.com/vita-dounai/Firework/object"
+)
+
+// scope lists the variables of environments, the innermost first. Outer
+// variables shadowed by inner ones are left out.
+type scope struct {
+	envs []*object.Environment
+}
+
+// frameScopes splits the environment chain of a frame into its locals and
+// the globals of its module, either is nil if empty.
+func frameScopes(env *object.Environment) (*scope, *scope) {
+	var locals, globals *scope
+
+	for ; env != nil; env = env.Outer() {
+		if env.Outer() == nil {
+			globals = &scope{envs: []*object.Environment{env}}
+			break
+		}
+
+		if locals == nil {
+			locals = &scope{}
+		}
+		locals.envs = append(locals.envs, env)
+	}
+
+	return locals, globals
+}
+
+// reference returns the variablesReference of v, valid while the program is
+// paused.
+func (s *Server) reference(v interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.references = append(s.references, v)
+	return len(s.references)
+}
+
+// children returns the variables of a scope or the elements of a value.
+func children(v interface{}) []object.Binding {
+	bindings := []object.Binding{}
+
+	switch v := v.(type) {
+	case *scope:
+		seen := map[string]bool{}
+		for _, env := range v.envs {
+			for _, binding := range env.Bindings() {
+				if _, ok := binding.Value.(*object.Module); ok && binding.Name == "import" {
+					// The module being evaluated
+					continue
+				}
+
+				if !seen[binding.Name] {
+					seen[binding.Name] = true
+					bindings = append(bindings, binding)
+				}
+			}
+		}
+	case *object.Array:
+		bindings = elementBindings(v.Elements)
+	case *object.Tuple:
+		bindings = elementBindings(v.Elements)
+	case *object.Map:
+		for _, pair := range v.OrderedPairs() {
+			bindings = append(bindings, object.Binding{Name: pair.Key.Inspect(), Value: pair.Value})
+		}
+	case *object.Struct:
+		for _, field := range v.Definition.Fields {
+			value, _ := v.Get(field)
+			bindings = append(bindings, object.Binding{Name: field, Value: value})
+		}
+	}
+
+	return bindings
+}
+
+func elementBindings(elements []object.Object) []object.Binding {
+	bindings := []object.Binding{}
+	for i, element := range elements {
+		bindings = append(bindings, object.Binding{Name: fmt.Sprintf("[%d]", i), Value: element})
+	}
+	return bindings
+}
+
+// variable describes value, compound values get a reference to expand them.
+func (s *Server) variable(name string, value object.Object) Variable {
+	variable := Variable{
+		Name:  name,
+		Value: s.debugger.Inspect(value),
+		Type:  string(value.Type()),
+	}
+
+	switch value := value.(type) {
+	case *object.Array:
+		if len(value.Elements) > 0 {
+			variable.VariablesReference = s.reference(value)
+		}
+	case *object.Tuple:
+		if len(value.Elements) > 0 {
+			variable.VariablesReference = s.reference(value)
+		}
+	case *object.Map:
+		if value.Len() > 0 {
+			variable.VariablesReference = s.reference(value)
+		}
+	case *object.Struct:
+		if len(value.Definition.Fields) > 0 {
+			variable.VariablesReference = s.reference(value)
+		}
+	}
+
+	return variable
+}
@@ -0,0 +1,362 @@
+package debugger
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/evaluator"
+	"github.com/vita-dounai/Firework/lexer"
+	"github.com/vita-dounai/Firework/object"
+	"github.com/vita-dounai/Firework/parser"
+)
+
+// Reasons the evaluation is paused for.
+const (
+	ENTRY               = "entry"
+	STEP                = "step"
+	BREAKPOINT          = "breakpoint"
+	FUNCTION_BREAKPOINT = "function breakpoint"
+)
+
+// Names of the frames which are not named functions.
+const (
+	MODULE_FRAME    = "<module>"
+	ANONYMOUS_FRAME = "<lambda>"
+)
+
+// Location is a line of a source file, Path is empty for code read by the
+// REPL.
+type Location struct {
+	Path string
+	Line int
+}
+
+func (l Location) String() string {
+	if l.Path == "" {
+		return fmt.Sprintf("line %d", l.Line)
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(l.Path), l.Line)
+}
+
+// Frame is a function call being evaluated, or the top level code. Location
+// and Env are those of the statement it is at.
+type Frame struct {
+	Name string
+	Location
+	Env *object.Environment
+}
+
+type stepping int
+
+const (
+	running stepping = iota
+	stepInto
+	stepOver
+	stepOut
+)
+
+// Debugger pauses the evaluation at breakpoints and while stepping, it is
+// installed with evaluator.SetHook. Spawned tasks and generators are not told
+// apart from the code which started them.
+type Debugger struct {
+	// Paused is called on the goroutine of the evaluation when it stops, the
+	// evaluation resumes once it returns. It calls Continue or one of the
+	// Step methods, or another goroutine does meanwhile.
+	Paused func(reason string)
+
+	mu        sync.Mutex
+	lines     map[Location]bool
+	functions map[string]bool
+
+	stepping stepping
+	reason   string // Of the next stop while stepping
+	depth    int    // Of the stack when stepping began
+	entered  bool   // A function breakpoint was hit
+
+	stack      []*Frame // Innermost last
+	evaluating bool     // Code is evaluated in a paused frame
+
+	// Held while paused, so that only one goroutine is paused at a time
+	pause sync.Mutex
+}
+
+func NewDebugger() *Debugger {
+	return &Debugger{
+		lines:     map[Location]bool{},
+		functions: map[string]bool{},
+	}
+}
+
+// Start prepares the debugger for a new evaluation, which is paused at its
+// first statement if stop is set.
+func (d *Debugger) Start(stop bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stack = []*Frame{{Name: MODULE_FRAME}}
+	d.stepping = running
+	d.entered = false
+
+	if stop {
+		d.stepping = stepInto
+		d.reason = ENTRY
+	}
+}
+
+func frameName(function *object.Function) string {
+	if function.Name == "" {
+		return ANONYMOUS_FRAME
+	}
+	return function.Name
+}
+
+func location(statement ast.Statement, env *object.Environment) Location {
+	location := Location{Line: statement.Pos().Line}
+	if module := evaluator.ModuleOf(env); module != nil {
+		location.Path = module.Path
+	}
+	return location
+}
+
+func (d *Debugger) Statement(statement ast.Statement, env *object.Environment) {
+	if statement.Pos().Line == 0 {
+		// Made by macros
+		return
+	}
+
+	d.mu.Lock()
+	if d.evaluating {
+		d.mu.Unlock()
+		return
+	}
+
+	if len(d.stack) == 0 {
+		d.stack = []*Frame{{Name: MODULE_FRAME}}
+	}
+
+	frame := d.stack[len(d.stack)-1]
+	current := location(statement, env)
+	// Statements on the line the frame is at do not stop it again
+	moved := current != frame.Location
+	frame.Location = current
+	frame.Env = env
+
+	reason := d.stopReason(moved)
+	if reason != "" {
+		d.stepping = running
+	}
+	d.mu.Unlock()
+
+	if reason != "" && d.Paused != nil {
+		d.pause.Lock()
+		d.Paused(reason)
+		d.pause.Unlock()
+	}
+}
+
+// stopReason must be called with the lock held.
+func (d *Debugger) stopReason(moved bool) string {
+	depth := len(d.stack)
+
+	switch {
+	case d.entered:
+		d.entered = false
+		return FUNCTION_BREAKPOINT
+	case moved && d.lines[d.stack[depth-1].Location]:
+		return BREAKPOINT
+	case d.stepping == stepInto && (moved || depth != d.depth):
+		return d.reason
+	case d.stepping == stepOver && (depth < d.depth || depth == d.depth && moved):
+		return d.reason
+	case d.stepping == stepOut && depth < d.depth:
+		return d.reason
+	default:
+		return ""
+	}
+}
+
+func (d *Debugger) Enter(function *object.Function, env *object.Environment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.evaluating {
+		return
+	}
+
+	d.stack = append(d.stack, &Frame{Name: frameName(function), Env: env})
+	if function.Name != "" && d.functions[function.Name] {
+		d.entered = true
+	}
+}
+
+func (d *Debugger) Leave(function *object.Function) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.evaluating && len(d.stack) > 1 {
+		d.stack = d.stack[:len(d.stack)-1]
+	}
+}
+
+func (d *Debugger) step(stepping stepping) {
+	d.mu.Lock()
+	d.stepping = stepping
+	d.reason = STEP
+	d.depth = len(d.stack)
+	d.mu.Unlock()
+}
+
+// Continue runs until the next breakpoint.
+func (d *Debugger) Continue() {
+	d.step(running)
+}
+
+// StepInto stops at the next line, in a function called or not.
+func (d *Debugger) StepInto() {
+	d.step(stepInto)
+}
+
+// StepOver stops at the next line of the current frame, or of its callers.
+func (d *Debugger) StepOver() {
+	d.step(stepOver)
+}
+
+// StepOut stops in the caller of the current frame.
+func (d *Debugger) StepOut() {
+	d.step(stepOut)
+}
+
+func (d *Debugger) AddBreakpoint(location Location) {
+	d.mu.Lock()
+	d.lines[location] = true
+	d.mu.Unlock()
+}
+
+// RemoveBreakpoint reports false if there was no breakpoint at location.
+func (d *Debugger) RemoveBreakpoint(location Location) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ok := d.lines[location]
+	delete(d.lines, location)
+	return ok
+}
+
+// SetBreakpoints replaces the breakpoints in the file at path.
+func (d *Debugger) SetBreakpoints(path string, lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for location := range d.lines {
+		if location.Path == path {
+			delete(d.lines, location)
+		}
+	}
+
+	for _, line := range lines {
+		d.lines[Location{Path: path, Line: line}] = true
+	}
+}
+
+// AddFunctionBreakpoint stops the evaluation when the function named name
+// is called.
+func (d *Debugger) AddFunctionBreakpoint(name string) {
+	d.mu.Lock()
+	d.functions[name] = true
+	d.mu.Unlock()
+}
+
+// RemoveFunctionBreakpoint reports false if there was no breakpoint on name.
+func (d *Debugger) RemoveFunctionBreakpoint(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ok := d.functions[name]
+	delete(d.functions, name)
+	return ok
+}
+
+// SetFunctionBreakpoints replaces all the function breakpoints.
+func (d *Debugger) SetFunctionBreakpoints(names []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.functions = map[string]bool{}
+	for _, name := range names {
+		d.functions[name] = true
+	}
+}
+
+// Breakpoints returns the line and function breakpoints, in no particular
+// order.
+func (d *Debugger) Breakpoints() ([]Location, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	locations := []Location{}
+	for location := range d.lines {
+		locations = append(locations, location)
+	}
+
+	names := []string{}
+	for name := range d.functions {
+		names = append(names, name)
+	}
+
+	return locations, names
+}
+
+// Stack returns the frames being evaluated, innermost first.
+func (d *Debugger) Stack() []Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	frames := []Frame{}
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		frames = append(frames, *d.stack[i])
+	}
+	return frames
+}
+
+// Evaluate evaluates source in the environment of a paused frame, without
+// stopping at breakpoints.
+func (d *Debugger) Evaluate(source string, env *object.Environment) object.Object {
+	l := lexer.NewLexer(source)
+	p := parser.NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		err := p.Errors()[0]
+		return &object.Error{Message: err.Type() + ": " + err.Info()}
+	}
+
+	var result object.Object
+	d.evaluate(func() { result = evaluator.Eval(program, env) })
+	return result
+}
+
+// Inspect formats value, whose to_string method must not stop at
+// breakpoints either.
+func (d *Debugger) Inspect(value object.Object) string {
+	var inspected string
+	d.evaluate(func() { inspected = value.Inspect() })
+	return inspected
+}
+
+// evaluate runs f with the hooks ignored.
+func (d *Debugger) evaluate(f func()) {
+	d.mu.Lock()
+	d.evaluating = true
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		d.evaluating = false
+		d.mu.Unlock()
+	}()
+
+	f()
+}
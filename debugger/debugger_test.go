@@ -0,0 +1,144 @@
+package debugger
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vita-dounai/Firework/evaluator"
+	"github.com/vita-dounai/Firework/lexer"
+	"github.com/vita-dounai/Firework/object"
+	"github.com/vita-dounai/Firework/parser"
+)
+
+const program = `fn add(a, b) {
+    c = a + b
+    c
+}
+x = 1
+y = add(x, 2)
+z = y * 2
+`
+
+// checkPauses evaluates program under a debugger, resuming it with each of
+// commands in turn, and returns where it paused.
+func checkPauses(t *testing.T, d *Debugger, stop bool, commands []func()) []string {
+	pauses := []string{}
+	d.Paused = func(reason string) {
+		frame := d.Stack()[0]
+		pauses = append(pauses, fmt.Sprintf("%s %s %d", reason, frame.Name, frame.Line))
+
+		if len(commands) == 0 {
+			d.Continue()
+			return
+		}
+		commands[0]()
+		commands = commands[1:]
+	}
+
+	l := lexer.NewLexer(program)
+	p := parser.NewParser()
+	p.Init(l)
+	parsed := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	env := object.NewEnvironment()
+	if err := evaluator.Resolve(parsed, env); err != nil {
+		t.Fatalf("resolver failed: %s", err.Inspect())
+	}
+
+	d.Start(stop)
+	evaluator.SetHook(d)
+	defer evaluator.SetHook(nil)
+
+	if result := evaluator.Eval(parsed, env); result != nil && result.Type() == object.ERROR_OBJ {
+		t.Fatalf("evaluation failed: %s", result.Inspect())
+	}
+	return pauses
+}
+
+func checkPausesEqual(t *testing.T, name string, got []string, expected []string) {
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("%s paused at %q, expected %q", name, got, expected)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	d := NewDebugger()
+	pauses := checkPauses(t, d, true, []func(){d.StepOver, d.StepOver, d.StepInto, d.StepInto, d.StepOut})
+	checkPausesEqual(t, "stepping", pauses, []string{
+		"entry <module> 1",
+		"step <module> 5",
+		"step <module> 6",
+		"step add 2",
+		"step add 3",
+		"step <module> 7",
+	})
+
+	d = NewDebugger()
+	pauses = checkPauses(t, d, true, []func(){d.StepInto, d.StepInto, d.StepInto, d.StepOver, d.StepOver})
+	checkPausesEqual(t, "stepping over a return", pauses, []string{
+		"entry <module> 1",
+		"step <module> 5",
+		"step <module> 6",
+		"step add 2",
+		"step add 3",
+		"step <module> 7",
+	})
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := NewDebugger()
+	d.AddBreakpoint(Location{Line: 3})
+	d.AddBreakpoint(Location{Line: 7})
+	pauses := checkPauses(t, d, false, nil)
+	checkPausesEqual(t, "line breakpoints", pauses, []string{"breakpoint add 3", "breakpoint <module> 7"})
+
+	if !d.RemoveBreakpoint(Location{Line: 3}) || d.RemoveBreakpoint(Location{Line: 4}) {
+		t.Errorf("wrong breakpoints removed")
+	}
+	pauses = checkPauses(t, d, false, nil)
+	checkPausesEqual(t, "removed breakpoint", pauses, []string{"breakpoint <module> 7"})
+
+	d = NewDebugger()
+	d.AddFunctionBreakpoint("add")
+	pauses = checkPauses(t, d, false, []func(){d.StepOut})
+	checkPausesEqual(t, "function breakpoint", pauses, []string{"function breakpoint add 2", "step <module> 7"})
+
+	d.SetFunctionBreakpoints(nil)
+	d.SetBreakpoints("", []int{5, 6})
+	pauses = checkPauses(t, d, false, nil)
+	checkPausesEqual(t, "replaced breakpoints", pauses, []string{"breakpoint <module> 5", "breakpoint <module> 6"})
+}
+
+func TestPausedFrame(t *testing.T) {
+	d := NewDebugger()
+	d.AddBreakpoint(Location{Line: 3})
+
+	var stack []Frame
+	var evaluated object.Object
+
+	checkPauses(t, d, false, []func(){func() {
+		stack = d.Stack()
+		evaluated = d.Evaluate("c * 10 + x", stack[0].Env)
+		d.Continue()
+	}})
+
+	if len(stack) != 2 || stack[0].Name != "add" || stack[1].Name != MODULE_FRAME || stack[1].Line != 6 {
+		t.Fatalf("wrong stack: %+v", stack)
+	}
+
+	bindings := stack[0].Env.Bindings()
+	if last := bindings[len(bindings)-1]; last.Name != "c" || last.Value.Inspect() != "3" {
+		t.Errorf("wrong bindings: %v", bindings)
+	}
+
+	if evaluated == nil || evaluated.Inspect() != "31" {
+		t.Errorf("wrong value evaluated: %v", evaluated)
+	}
+
+	if err := d.Evaluate("c +", stack[0].Env); err.Type() != object.ERROR_OBJ {
+		t.Errorf("expected a parse error, got %s", err.Inspect())
+	}
+}
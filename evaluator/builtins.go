@@ -2,11 +2,17 @@ package evaluator
 
 import (
 	"fmt"
-	"strings"
+	"io"
+	"math"
+	"os"
+	"unicode/utf8"
 
 	"github.com/vita-dounai/Firework/object"
 )
 
+// Output is where print writes.
+var Output io.Writer = os.Stdout
+
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
@@ -16,9 +22,13 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Tuple:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Map:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("Argument to `len` not supported, got %s",
 					arg.Type())
@@ -27,48 +37,80 @@ var builtins = map[string]*object.Builtin{
 	},
 	"first": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 || args[0].Type() != object.ARRAY_OBJ {
-				return newError("Argument to `first` must be ARRAY, got %s", args[0].Type())
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
 			}
 
-			array := args[0].(*object.Array)
-			if len(array.Elements) > 0 {
-				return array.Elements[0]
+			iterator, err := iterate(args[0])
+			if err != nil {
+				return err
 			}
 
-			return NULL
+			value, ok := iterator.Next()
+			if !ok {
+				return NULL
+			}
+
+			return value
 		},
 	},
 	"last": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 || args[0].Type() != object.ARRAY_OBJ {
-				return newError("Argument to `last` must be ARRAY, got %s", args[0].Type())
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
 			}
 
-			array := args[0].(*object.Array)
-			length := len(array.Elements)
-			if length > 0 {
-				return array.Elements[length-1]
+			iterator, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+
+			elements, err := collect(iterator)
+			if err != nil {
+				return err
+			}
+
+			if len(elements) > 0 {
+				return elements[len(elements)-1]
 			}
 
 			return NULL
 		},
 	},
+	// rest of an array is an array, of other iterables it is an iterator
+	// positioned after their first element
 	"rest": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 || args[0].Type() != object.ARRAY_OBJ {
-				return newError("Argument to `rest` must be ARRAY, got %s", args[0].Type())
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
 			}
 
-			array := args[0].(*object.Array)
-			length := len(array.Elements)
-			if length > 0 {
-				rest := make([]object.Object, length-1, length-1)
-				copy(rest, array.Elements[1:length])
-				return &object.Array{Elements: rest}
+			if array, ok := args[0].(*object.Array); ok {
+				length := len(array.Elements)
+				if length > 0 {
+					rest := make([]object.Object, length-1, length-1)
+					copy(rest, array.Elements[1:length])
+					return &object.Array{Elements: rest}
+				}
+
+				return NULL
 			}
 
-			return NULL
+			iterator, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+
+			value, ok := iterator.Next()
+			if !ok {
+				return NULL
+			}
+
+			if isError(value) {
+				return value
+			}
+
+			return iterator
 		},
 	},
 	"push": &object.Builtin{
@@ -86,24 +128,249 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newArray}
 		},
 	},
-	"print": &object.Builtin{
+	"keys": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			length := len(args)
-			for i, arg := range args {
-				var inspect string
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.MAP_OBJ {
+				return newError("Argument to `keys` must be MAP, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Map).OrderedPairs()
+			keys := make([]object.Object, len(pairs), len(pairs))
+			for i, pair := range pairs {
+				keys[i] = pair.Key
+			}
 
-				if _, ok := arg.(*object.String); ok {
-					inspect = strings.Trim(arg.Inspect(), "\"")
-				} else {
-					inspect = arg.Inspect()
+			return &object.Array{Elements: keys}
+		},
+	},
+	"values": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.MAP_OBJ {
+				return newError("Argument to `values` must be MAP, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Map).OrderedPairs()
+			values := make([]object.Object, len(pairs), len(pairs))
+			for i, pair := range pairs {
+				values[i] = pair.Value
+			}
+
+			return &object.Array{Elements: values}
+		},
+	},
+	"iter": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			iterator, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+
+			return iterator
+		},
+	},
+	"next": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.ITERATOR_OBJ {
+				return newError("Argument to `next` must be ITERATOR, got %s", args[0].Type())
+			}
+
+			value, ok := args[0].(*object.Iterator).Next()
+			if !ok {
+				return NULL
+			}
+
+			return value
+		},
+	},
+	"done": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.ITERATOR_OBJ {
+				return newError("Argument to `done` must be ITERATOR, got %s", args[0].Type())
+			}
+
+			return nativeBoolToBooleanObject(args[0].(*object.Iterator).Done())
+		},
+	},
+	"array": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			iterator, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+
+			elements, err := collect(iterator)
+			if err != nil {
+				return err
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"take": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("Wrong number of arguments, got=%d, want=2", len(args))
+			}
+
+			iterator, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+
+			var count int64
+			switch n := args[1].(type) {
+			case *object.Integer:
+				count = n.Value
+			case *object.BigInteger:
+				// Too many to ever be taken, or fewer than none
+				count = math.MaxInt64
+				if n.Value.Sign() < 0 {
+					count = 0
+				}
+			default:
+				return newError("Argument to `take` must be INTEGER, got %s", args[1].Type())
+			}
+
+			taken := int64(0)
+			return object.NewIterator("iterator", func() (object.Object, bool) {
+				if taken >= count {
+					return nil, false
+				}
+
+				taken++
+				return iterator.Next()
+			})
+		},
+	},
+	"chan": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return object.NewChannel(0)
+			}
+
+			capacity, ok := args[0].(*object.Integer)
+			if len(args) != 1 || !ok || capacity.Value < 0 {
+				return newError("Argument to `chan` must be a non-negative INTEGER")
+			}
+
+			return object.NewChannel(int(capacity.Value))
+		},
+	},
+	"send": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("Wrong number of arguments, got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.CHANNEL_OBJ {
+				return newError("Argument to `send` must be CHANNEL, got %s", args[0].Type())
+			}
+
+			if err := args[0].(*object.Channel).Send(args[1]); err != nil {
+				return newError("%s", err)
+			}
+
+			return NULL
+		},
+	},
+	"recv": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.CHANNEL_OBJ {
+				return newError("Argument to `recv` must be CHANNEL, got %s", args[0].Type())
+			}
+
+			value, ok, err := args[0].(*object.Channel).Recv()
+			if err != nil {
+				return newError("%s", err)
+			}
+
+			if !ok {
+				return NULL
+			}
+
+			return value
+		},
+	},
+	"close": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("Wrong number of arguments, got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.CHANNEL_OBJ {
+				return newError("Argument to `close` must be CHANNEL, got %s", args[0].Type())
+			}
+
+			if err := args[0].(*object.Channel).Close(); err != nil {
+				return newError("%s", err)
+			}
+
+			return NULL
+		},
+	},
+	"implements": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("Wrong number of arguments, got=%d, want=2", len(args))
+			}
+
+			trait, ok := args[1].(*object.Trait)
+			if !ok {
+				return newError("Argument to `implements` must be TRAIT, got %s", args[1].Type())
+			}
+
+			s, ok := args[0].(*object.Struct)
+			return nativeBoolToBooleanObject(ok && s.Definition.Traits[trait])
+		},
+	},
+	"print": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			// Nothing is printed if an argument can not be shown
+			strs := []string{}
+			for _, arg := range args {
+				str, err := toDisplayString(arg)
+				if err != nil {
+					return err
 				}
+				strs = append(strs, str)
+			}
 
-				fmt.Print(inspect)
+			length := len(args)
+			for i, str := range strs {
+				fmt.Fprint(Output, str)
 				if i < length {
-					fmt.Print(" ")
+					fmt.Fprint(Output, " ")
 				}
 			}
-			fmt.Print("\n")
+			fmt.Fprint(Output, "\n")
 			return nil
 		},
 	},
@@ -0,0 +1,76 @@
+package evaluator
+
+import (
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+// evalSpawnExpression evaluates the function and its arguments right away
+// and applies it on a new task. The returned channel receives the result.
+func evalSpawnExpression(se *ast.SpawnExpression, env *object.Environment) object.Object {
+	function, args := evalCallee(se.Call, env)
+	if isError(function) {
+		return function
+	}
+
+	result := object.NewChannel(1)
+	object.Spawn(func() {
+		result.Send(applyFunction(function, args))
+		result.Close()
+	})
+
+	return result
+}
+
+func evalSelectExpression(se *ast.SelectExpression, env *object.Environment) object.Object {
+	cases := []object.SelectCase{}
+	// Index of the ast case for each entry of cases
+	indices := []int{}
+	defaultCase := -1
+
+	for i, c := range se.Cases {
+		if c.Call == nil {
+			defaultCase = i
+			continue
+		}
+
+		arguments := evalExpressions(c.Call.Arguments, env)
+		if len(arguments) == 1 && isError(arguments[0]) {
+			return arguments[0]
+		}
+
+		channel, ok := arguments[0].(*object.Channel)
+		if !ok {
+			return newError("Select case must be on CHANNEL, got %s", arguments[0].Type())
+		}
+
+		selectCase := object.SelectCase{Channel: channel}
+		if len(arguments) == 2 {
+			selectCase.Send = true
+			selectCase.Value = arguments[1]
+		}
+
+		cases = append(cases, selectCase)
+		indices = append(indices, i)
+	}
+
+	chosen, value, ok, err := object.Select(cases, defaultCase >= 0)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	selected := defaultCase
+	if chosen >= 0 {
+		selected = indices[chosen]
+	}
+
+	caseEnv := object.ExtendEnvironment(env)
+	if name := se.Cases[selected].Name; name != nil {
+		if !ok {
+			value = NULL
+		}
+		caseEnv.Define(name.Value, value)
+	}
+
+	return Eval(se.Cases[selected].Body, caseEnv)
+}
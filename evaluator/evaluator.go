@@ -2,6 +2,8 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"strings"
 
 	"github.com/vita-dounai/Firework/ast"
@@ -20,15 +22,49 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// hoistFunctions defines the named functions declared directly in statements
+// so they can be called before their declarations.
+func hoistFunctions(statements []ast.Statement, env *object.Environment) {
+	for _, statement := range statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			statement = export.Statement
+		}
+
+		if declaration, ok := statement.(*ast.FunctionStatement); ok {
+			defineFunction(declaration.Function, env)
+		}
+	}
+}
+
+func newFunction(function *ast.FunctionLiteral, env *object.Environment) *object.Function {
+	return &object.Function{
+		Name:        function.Name,
+		Parameters:  function.Parameters,
+		Body:        function.Body,
+		Env:         env,
+		IsGenerator: function.IsGenerator,
+	}
+}
+
+func defineFunction(function *ast.FunctionLiteral, env *object.Environment) {
+	env.Define(function.Name, newFunction(function, env))
+}
+
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	hoistFunctions(program.Statements, env)
+
 	for _, statement := range program.Statements {
+		if hook != nil {
+			hook.Statement(statement, env)
+		}
+
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
+			return completeTailCall(result.Value)
 		case *object.Error:
 			return result
 		}
@@ -61,13 +97,29 @@ func evalExclamationOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("Unknown operator: -%s", right.Type())
-	}
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
 
-	value := right.(*object.Integer).Value
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	default:
+		return newError("Unknown operator: -%s", typeName(right))
+	}
+}
 
-	return &object.Integer{Value: -value}
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Not(right.Value))
+	default:
+		return newError("Unknown operator: ~%s", right.Type())
+	}
 }
 
 func evalPrefixExpression(operator string, right object.Object) object.Object {
@@ -75,51 +127,210 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "!":
 		return evalExclamationOperatorExpression(right)
 	case "-":
+		if method, ok := structMethod(right, "neg"); ok {
+			return applyFunction(method, []object.Object{right})
+		}
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalTildePrefixOperatorExpression(right)
 	default:
 		return newError("Unknown operator: %s%s", operator, right.Type())
 	}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+	leftInteger, leftOk := left.(*object.Integer)
+	rightInteger, rightOk := right.(*object.Integer)
+
+	if leftOk && rightOk {
+		if result, ok := evalInt64InfixExpression(operator, leftInteger.Value, rightInteger.Value); ok {
+			return result
+		}
+	}
+
+	// At least one side does not fit in int64, or the int64 arithmetic overflowed
+	return evalBigIntegerInfixExpression(operator, left, right)
+}
 
+// evalInt64InfixExpression reports false when the result overflows int64.
+func evalInt64InfixExpression(operator string, leftValue, rightValue int64) (object.Object, bool) {
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		result := leftValue + rightValue
+		if (result > leftValue) != (rightValue > 0) {
+			return nil, false
+		}
+		return &object.Integer{Value: result}, true
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		result := leftValue - rightValue
+		if (result < leftValue) != (rightValue > 0) {
+			return nil, false
+		}
+		return &object.Integer{Value: result}, true
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		if leftValue == 0 || rightValue == 0 {
+			return &object.Integer{Value: 0}, true
+		}
+
+		result := leftValue * rightValue
+		if result/rightValue != leftValue || (leftValue == -1 && rightValue == math.MinInt64) ||
+			(rightValue == -1 && leftValue == math.MinInt64) {
+			return nil, false
+		}
+		return &object.Integer{Value: result}, true
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		if rightValue == 0 {
+			return newError("Division by zero"), true
+		}
+
+		if leftValue == math.MinInt64 && rightValue == -1 {
+			return nil, false
+		}
+		return &object.Integer{Value: leftValue / rightValue}, true
 	case "**":
 		result := int64(1)
 		for i := rightValue; i > 0; i >>= 1 {
 			if i&1 != 0 {
-				result *= leftValue
+				product, ok := evalInt64InfixExpression("*", result, leftValue)
+				if !ok {
+					return nil, false
+				}
+				result = product.(*object.Integer).Value
+			}
+
+			if i > 1 {
+				square, ok := evalInt64InfixExpression("*", leftValue, leftValue)
+				if !ok {
+					return nil, false
+				}
+				leftValue = square.(*object.Integer).Value
 			}
-			leftValue *= leftValue
 		}
 
-		return &object.Integer{Value: result}
+		return &object.Integer{Value: result}, true
+	case "%":
+		if rightValue == 0 {
+			return newError("Division by zero"), true
+		}
+
+		if rightValue == -1 {
+			return &object.Integer{Value: 0}, true
+		}
+		return &object.Integer{Value: leftValue % rightValue}, true
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}, true
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}, true
+	case "bor":
+		return &object.Integer{Value: leftValue | rightValue}, true
+	case "<<":
+		if rightValue < 0 {
+			return newError("Negative shift count"), true
+		}
+
+		if leftValue == 0 {
+			return &object.Integer{Value: 0}, true
+		}
+
+		if rightValue >= 63 || (leftValue<<uint(rightValue))>>uint(rightValue) != leftValue {
+			return nil, false
+		}
+		return &object.Integer{Value: leftValue << uint(rightValue)}, true
+	case ">>":
+		if rightValue < 0 {
+			return newError("Negative shift count"), true
+		}
+
+		if rightValue > 63 {
+			rightValue = 63
+		}
+		return &object.Integer{Value: leftValue >> uint(rightValue)}, true
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue), true
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue), true
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue), true
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue), true
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue), true
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue), true
+	default:
+		return newError("Unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ), true
+	}
+}
+
+func evalBigIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := object.ToBigInt(left)
+	rightValue := object.ToBigInt(right)
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		return object.NewInteger(result.Add(leftValue, rightValue))
+	case "-":
+		return object.NewInteger(result.Sub(leftValue, rightValue))
+	case "*":
+		return object.NewInteger(result.Mul(leftValue, rightValue))
+	case "/":
+		if rightValue.Sign() == 0 {
+			return newError("Division by zero")
+		}
+		return object.NewInteger(result.Quo(leftValue, rightValue))
+	case "**":
+		if rightValue.Sign() < 0 {
+			return &object.Integer{Value: 1}
+		}
+		return object.NewInteger(result.Exp(leftValue, rightValue, nil))
 	case "%":
-		return &object.Integer{Value: leftValue % rightValue}
+		if rightValue.Sign() == 0 {
+			return newError("Division by zero")
+		}
+		return object.NewInteger(result.Rem(leftValue, rightValue))
+	case "&":
+		return object.NewInteger(result.And(leftValue, rightValue))
+	case "^":
+		return object.NewInteger(result.Xor(leftValue, rightValue))
+	case "bor":
+		return object.NewInteger(result.Or(leftValue, rightValue))
+	case "<<":
+		if rightValue.Sign() < 0 {
+			return newError("Negative shift count")
+		}
+
+		if !rightValue.IsInt64() || rightValue.Int64() > math.MaxInt32 {
+			return newError("Shift count too large")
+		}
+		return object.NewInteger(result.Lsh(leftValue, uint(rightValue.Int64())))
+	case ">>":
+		if rightValue.Sign() < 0 {
+			return newError("Negative shift count")
+		}
+
+		if !rightValue.IsInt64() || rightValue.Int64() > math.MaxInt32 {
+			// Every bit is shifted out
+			if leftValue.Sign() < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: 0}
+		}
+		return object.NewInteger(result.Rsh(leftValue, uint(rightValue.Int64())))
 	case ">":
-		return nativeBoolToBooleanObject(leftValue > rightValue)
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) > 0)
 	case ">=":
-		return nativeBoolToBooleanObject(leftValue >= rightValue)
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) >= 0)
 	case "<":
-		return nativeBoolToBooleanObject(leftValue < rightValue)
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) < 0)
 	case "<=":
-		return nativeBoolToBooleanObject(leftValue <= rightValue)
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) <= 0)
 	case "==":
-		return nativeBoolToBooleanObject(leftValue == rightValue)
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) == 0)
 	case "!=":
-		return nativeBoolToBooleanObject(leftValue != rightValue)
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) != 0)
 	default:
-		return newError("Unknown operator: %s %s  %s", left.Type(), operator, right.Type())
+		return newError("Unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -144,21 +355,27 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	if result, ok := evalOverloadedInfixExpression(operator, left, right); ok {
+		return result
+	}
+
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
-	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+	case operator == "==" || operator == "!=":
+		equal, err := object.Equal(left, right)
+		if err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(equal == (operator == "=="))
 	default:
 		switch {
-		case left.Type() != right.Type():
-			return newError("Type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		case typeName(left) != typeName(right):
+			return newError("Type mismatch: %s %s %s", typeName(left), operator, typeName(right))
 		default:
-			return newError("Unknown operator: %s %s %s", left.Type(), operator, right.Type())
+			return newError("Unknown operator: %s %s %s", typeName(left), operator, typeName(right))
 		}
 	}
 }
@@ -174,29 +391,35 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment, tail bool) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return evalBranch(ie.Consequence, env, tail)
 	}
 
 	if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalBranch(ie.Alternative, env, tail)
 	}
 
 	return NULL
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	extendedEnv := object.ExtendEnvironment(env)
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
+	extendedEnv := object.NewFrame(env, block.Names)
 	var result object.Object
 
-	for _, statement := range block.Statements {
-		result = Eval(statement, extendedEnv)
+	hoistFunctions(block.Statements, extendedEnv)
+
+	for i, statement := range block.Statements {
+		if hook != nil {
+			hook.Statement(statement, extendedEnv)
+		}
+
+		result = evalBranch(statement, extendedEnv, tail && i == len(block.Statements)-1)
 
 		if result != nil {
 			switch result.Type() {
@@ -239,7 +462,11 @@ func isBreak(obj object.Object) bool {
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if value, ok := env.Get(node.Value); ok {
+	if node.Slot != nil {
+		if value, ok := env.GetAt(node.Slot.Depth, node.Slot.Index); ok {
+			return value
+		}
+	} else if value, ok := env.Get(node.Value); ok {
 		return value
 	}
 
@@ -247,13 +474,23 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
-	return newError("Identifier not found: " + node.Value)
+	return newError("Identifier not found: %s", node.Value)
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			elements, err := evalSpreadExpression(spread, env)
+			if err != nil {
+				return []object.Object{err}
+			}
+
+			result = append(result, elements...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -269,7 +506,7 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviro
 	extendedEnv := object.ExtendEnvironment(fn.Env)
 
 	for idx, param := range fn.Parameters {
-		extendedEnv.Set(param.Value, args[idx])
+		extendedEnv.Define(param.Value, args[idx])
 	}
 
 	return extendedEnv
@@ -285,49 +522,317 @@ func unwrapReturnValue(obj object.Object) object.Object {
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch function := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(function, args)
-		evaluated := Eval(function.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		// Calls in tail position come back as tailCall and are run by this
+		// loop instead of recursing
+		for {
+			if len(args) != len(function.Parameters) {
+				if function.Name != "" {
+					return newError("Wrong number of arguments to `%s`, got=%d, want=%d",
+						function.Name, len(args), len(function.Parameters))
+				}
+				return newError("Wrong number of arguments, got=%d, want=%d", len(args), len(function.Parameters))
+			}
+
+			extendedEnv := extendFunctionEnv(function, args)
+			if function.IsGenerator {
+				return newGenerator(function, extendedEnv)
+			}
+
+			if hook != nil {
+				hook.Enter(function, extendedEnv)
+			}
+
+			evaluated := unwrapReturnValue(evalTail(function.Body, extendedEnv))
+
+			if hook != nil {
+				hook.Leave(function)
+			}
+
+			if call, ok := evaluated.(*tailCall); ok {
+				function, args = call.function, call.args
+				continue
+			}
+
+			if err, ok := evaluated.(*object.Error); ok && function.Name != "" {
+				err.Trace = append(err.Trace, function.Name)
+			}
+
+			return evaluated
+		}
 	case *object.Builtin:
 		return function.Fn(args...)
+	case *object.StructType:
+		return constructStruct(function, args)
 	default:
 		return newError("Not a function: %s", fn.Type())
 	}
 }
 
+// resolveSubscript maps a possibly negative subscript onto [0, length), the
+// returned object is non-nil if the subscript is out of range or invalid.
+func resolveSubscript(indexObject object.Object, length int) (int, object.Object) {
+	if _, ok := indexObject.(*object.BigInteger); ok {
+		// Always out of range
+		return 0, NULL
+	}
+
+	index, ok := indexObject.(*object.Integer)
+	if !ok {
+		return 0, newError("Subscript not support: %s", indexObject.Type())
+	}
+
+	subscript := index.Value
+	if subscript < 0 {
+		subscript += int64(length)
+	}
+
+	if subscript < 0 || subscript >= int64(length) {
+		return 0, NULL
+	}
+
+	return int(subscript), nil
+}
+
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for i, str := range node.Strings {
+		out.WriteString(str)
+
+		if i < len(node.Expressions) {
+			value := Eval(node.Expressions[i], env)
+			if isError(value) {
+				return value
+			}
+
+			str, err := toDisplayString(value)
+			if err != nil {
+				return err
+			}
+			out.WriteString(str)
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// toDisplayString is Inspect without quotes around strings, or the error
+// raised by a to_string method.
+func toDisplayString(obj object.Object) (string, object.Object) {
+	if obj == nil {
+		return NULL.Inspect(), nil
+	}
+
+	if str, ok := obj.(*object.String); ok {
+		return str.Value, nil
+	}
+
+	return object.Inspect(obj)
+}
+
+// asMapKey returns key as the key of a map, or the error of using it as one.
+func asMapKey(key object.Object) (object.Hashable, object.Object) {
+	hashable, ok := object.AsHashable(key)
+	if !ok {
+		return nil, newError("unusable as map key: %s", key.Type())
+	}
+
+	if err := object.HashError(hashable); err != nil {
+		return nil, err
+	}
+
+	return hashable, nil
+}
+
 func evalIndexExpression(leftObject, indexObject object.Object) object.Object {
 	switch left := leftObject.(type) {
 	case *object.Array:
-		index, ok := indexObject.(*object.Integer)
-		if !ok {
-			return newError("Subscript not support: %s", index.Type())
+		subscript, failure := resolveSubscript(indexObject, len(left.Elements))
+		if failure != nil {
+			return failure
 		}
 
-		subscript := index.Value
-
-		if subscript < 0 || subscript >= int64(len(left.Elements)) {
-			return NULL
+		return left.Elements[subscript]
+	case *object.Tuple:
+		subscript, failure := resolveSubscript(indexObject, len(left.Elements))
+		if failure != nil {
+			return failure
 		}
 
 		return left.Elements[subscript]
+	case *object.String:
+		runes := []rune(left.Value)
+		subscript, failure := resolveSubscript(indexObject, len(runes))
+		if failure != nil {
+			return failure
+		}
+
+		return &object.String{Value: string(runes[subscript])}
 	case *object.Map:
-		index, ok := indexObject.(object.Hashable)
+		index, err := asMapKey(indexObject)
+		if err != nil {
+			return err
+		}
 
+		value, ok := left.Get(index)
 		if !ok {
-			return newError("unusable as map key: %s", indexObject.Type())
+			return NULL
 		}
 
-		hashKey := index.Hash()
-		pair, ok := left.Pairs[hashKey]
+		return value
+	case *object.Module:
+		name, ok := indexObject.(*object.String)
 		if !ok {
-			return NULL
+			return newError("Subscript not support: %s", indexObject.Type())
 		}
 
-		return pair.Value
+		value, ok := left.Get(name.Value)
+		if !ok {
+			return newError("Module %s has no export: %s", left.Name, name.Value)
+		}
+
+		return value
 	}
 	return NULL
 }
 
+func sliceBound(obj object.Object) (int64, object.Object) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInteger:
+		if obj.Value.Sign() > 0 {
+			return math.MaxInt64, nil
+		}
+		return -math.MaxInt64, nil
+	default:
+		return 0, newError("Slice index not support: %s", obj.Type())
+	}
+}
+
+// sliceIndices follows the semantics of Python slices, nil bounds are the
+// omitted ones.
+func sliceIndices(length int, startObject, endObject, stepObject object.Object) ([]int, object.Object) {
+	step := int64(1)
+	if stepObject != nil {
+		var failure object.Object
+		if step, failure = sliceBound(stepObject); failure != nil {
+			return nil, failure
+		}
+
+		if step == 0 {
+			return nil, newError("Slice step cannot be zero")
+		}
+
+		if step == math.MinInt64 {
+			step = -math.MaxInt64
+		}
+	}
+
+	lower, upper := int64(0), int64(length)
+	if step < 0 {
+		lower, upper = -1, int64(length)-1
+	}
+
+	bound := func(obj object.Object, omitted int64) (int64, object.Object) {
+		if obj == nil {
+			return omitted, nil
+		}
+
+		value, failure := sliceBound(obj)
+		if failure != nil {
+			return 0, failure
+		}
+
+		if value < 0 {
+			value += int64(length)
+			if value < lower {
+				value = lower
+			}
+		} else if value > upper {
+			value = upper
+		}
+
+		return value, nil
+	}
+
+	var start, end int64
+	var failure object.Object
+	if step > 0 {
+		start, failure = bound(startObject, lower)
+		if failure == nil {
+			end, failure = bound(endObject, upper)
+		}
+	} else {
+		start, failure = bound(startObject, upper)
+		if failure == nil {
+			end, failure = bound(endObject, lower)
+		}
+	}
+
+	if failure != nil {
+		return nil, failure
+	}
+
+	count := int64(0)
+	if step > 0 && start < end {
+		count = (end-start-1)/step + 1
+	} else if step < 0 && start > end {
+		count = (start-end-1)/(-step) + 1
+	}
+
+	indices := make([]int, count)
+	for i := range indices {
+		indices[i] = int(start + int64(i)*step)
+	}
+
+	return indices, nil
+}
+
+func evalSliceExpression(leftObject, start, end, step object.Object) object.Object {
+	switch left := leftObject.(type) {
+	case *object.Array:
+		indices, failure := sliceIndices(len(left.Elements), start, end, step)
+		if failure != nil {
+			return failure
+		}
+
+		elements := make([]object.Object, len(indices))
+		for i, index := range indices {
+			elements[i] = left.Elements[index]
+		}
+
+		return &object.Array{Elements: elements}
+	case *object.Tuple:
+		indices, failure := sliceIndices(len(left.Elements), start, end, step)
+		if failure != nil {
+			return failure
+		}
+
+		elements := make([]object.Object, len(indices))
+		for i, index := range indices {
+			elements[i] = left.Elements[index]
+		}
+
+		return &object.Tuple{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		indices, failure := sliceIndices(len(runes), start, end, step)
+		if failure != nil {
+			return failure
+		}
+
+		sliced := make([]rune, len(indices))
+		for i, index := range indices {
+			sliced[i] = runes[index]
+		}
+
+		return &object.String{Value: string(sliced)}
+	default:
+		return newError("Slice operator not support: %s", leftObject.Type())
+	}
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
@@ -336,8 +841,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return object.NewInteger(new(big.Int).Set(node.Value))
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		if node.Value {
 			return TRUE
@@ -362,11 +871,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, env, false)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, false)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env, false)
 	case *ast.ReturnStatement:
-		returnValue := Eval(node.ReturnValue, env)
+		// A call returned comes back as a tailCall inside the ReturnValue
+		returnValue := evalTail(node.ReturnValue, env)
 		if isError(returnValue) {
 			return returnValue
 		}
@@ -377,28 +889,41 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return value
 		}
 
-		env.Set(node.Name.Value, value)
+		if slot := node.Name.Slot; slot != nil {
+			env.SetAt(slot.Depth, slot.Index, node.Name.Value, value)
+		} else {
+			env.Set(node.Name.Value, value)
+		}
+	case *ast.FieldAssignStatement:
+		return evalFieldAssignStatement(node, env)
+	case *ast.StructStatement:
+		return evalStructStatement(node, env)
+	case *ast.TraitStatement:
+		return evalTraitStatement(node, env)
+	case *ast.ImplStatement:
+		return evalImplStatement(node, env)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
+	case *ast.FunctionStatement:
+		// Usually already hoisted, defining again keeps the statement
+		// meaningful on its own
+		defineFunction(node.Function, env)
 	case *ast.FunctionLiteral:
 		parameters := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: parameters, Body: body, Env: env}
+		return &object.Function{Parameters: parameters, Body: body, Env: env, IsGenerator: node.IsGenerator}
 	case *ast.CallExpression:
 		if name, ok := node.Function.(*ast.Identifier); ok && name.Value == "quote" {
 			return quote(node.Arguments[0], env)
 		}
 
-		function := Eval(node.Function, env)
+		function, args := evalCallee(node, env)
 		if isError(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-
 		return applyFunction(function, args)
 	case *ast.WhileStatement:
 		for true {
@@ -421,6 +946,20 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 				break
 			}
 		}
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
+	case *ast.SpreadExpression:
+		return newError("Spread not support here: %s", node.String())
 	case *ast.BreakStatement:
 		return BREAK
 	case *ast.ContinueStatement:
@@ -432,13 +971,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		return &object.Array{Elements: elements}
+	case *ast.TupleLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+
+		return &object.Tuple{Elements: elements}
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		if left.Type() != object.ARRAY_OBJ && left.Type() != object.MAP_OBJ {
+		switch left.Type() {
+		case object.ARRAY_OBJ, object.TUPLE_OBJ, object.STRING_OBJ, object.MAP_OBJ, object.MODULE_OBJ:
+		default:
 			return newError("Index operator not support: %s", left.Type())
 		}
 
@@ -448,30 +996,49 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		bounds := []object.Object{}
+		for _, boundNode := range []ast.Expression{node.Start, node.End, node.Step} {
+			var bound object.Object
+			if boundNode != nil {
+				bound = Eval(boundNode, env)
+				if isError(bound) {
+					return bound
+				}
+			}
+
+			bounds = append(bounds, bound)
+		}
+
+		return evalSliceExpression(left, bounds[0], bounds[1], bounds[2])
 	case *ast.MapLiteral:
-		pairs := make(map[object.HashKey]object.MapPair)
+		mapObject := object.NewMap()
 
-		for keyNode, valueNode := range node.Pairs {
-			key := Eval(keyNode, env)
+		for _, pair := range node.Pairs {
+			key := Eval(pair.Key, env)
 			if isError(key) {
 				return key
 			}
 
-			hashableKeyObject, ok := key.(object.Hashable)
-			if !ok {
-				return newError("unusable as map key: %s", key.Type())
+			hashableKeyObject, err := asMapKey(key)
+			if err != nil {
+				return err
 			}
 
-			value := Eval(valueNode, env)
+			value := Eval(pair.Value, env)
 			if isError(value) {
 				return value
 			}
 
-			hashKey := hashableKeyObject.Hash()
-			pairs[hashKey] = object.MapPair{Key: key, Value: value}
+			mapObject.Set(hashableKeyObject, value)
 		}
 
-		return &object.Map{Pairs: pairs}
+		return mapObject
 	}
 
 	return nil
@@ -1,11 +1,18 @@
 package evaluator
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"testing"
 
 	"github.com/vita-dounai/Firework/lexer"
 	"github.com/vita-dounai/Firework/object"
+	"github.com/vita-dounai/Firework/optimizer"
 	"github.com/vita-dounai/Firework/parser"
 )
 
@@ -48,6 +55,10 @@ func checkEval(input string) object.Object {
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
 
+	if err := Resolve(program, env); err != nil {
+		return err
+	}
+
 	return Eval(program, env)
 }
 
@@ -84,6 +95,86 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalBigIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 ** 64", "18446744073709551616"},
+		{"2 ** 63 - 1", "9223372036854775807"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"18446744073709551616 / 4294967296", "4294967296"},
+		{"18446744073709551617 % 10", "7"},
+		{"-18446744073709551617 % 10", "-7"},
+		{"100000000000000000000 - 100000000000000000000", "0"},
+		{"2 ** 64 > 2 ** 63", "true"},
+		{"2 ** 64 == 18446744073709551616", "true"},
+		{"2 ** 64 == 1", "false"},
+		{`
+		factorial = |n| {
+			if n <= 1 {
+				return 1;
+			}
+			return n * factorial(n - 1);
+		};
+		factorial(25);
+		`, "15511210043330985984000000"},
+		{`{2 ** 64: "big"}[18446744073709551616]`, `"big"`},
+		{"1 / 0", "Division by zero"},
+		{"2 ** 64 % 0", "Division by zero"},
+		{`2 ** 64 + "a"`, "Type mismatch: INTEGER + STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+
+	// Results that fit in int64 are normalized back
+	checkIntegerObject(t, checkEval("2 ** 64 / 2 ** 60"), 16)
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0b1100 & 0b1010", "8"},
+		{"0b1100 ^ 0b1010", "6"},
+		{"0b1100 bor 0b1010", "14"},
+		{"~0", "-1"},
+		{"~5", "-6"},
+		{"1 << 10", "1024"},
+		{"1 << 64", "18446744073709551616"},
+		{"-1 << 63", "-9223372036854775808"},
+		{"3 << 62", "13835058055282163712"},
+		{"1024 >> 3", "128"},
+		{"-16 >> 2", "-4"},
+		{"-1 >> 100", "-1"},
+		{"(1 << 100) >> 99", "2"},
+		{"(1 << 64) & 0xFFFF", "0"},
+		{"(1 << 64) bor 1", "18446744073709551617"},
+		{"~(1 << 64)", "-18446744073709551617"},
+		{"0x10 + 0o10 + 0b10 + 1_0", "36"},
+		{"1 << -1", "Negative shift count"},
+		{"true & false", "Unknown operator: BOOLEAN & BOOLEAN"},
+		{"~true", "Unknown operator: ~BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -334,6 +425,38 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`name = "Bob"; items = [1, 2]; "Hello ${name}, you have ${len(items)} items"`, "Hello Bob, you have 2 items"},
+		{`"${1 + 2}${true}"`, "3true"},
+		{`"list: ${[1, "a"]}, map: ${{"k": "v"}}"`, `list: [1, "a"], map: {"k": "v"}`},
+		{`x = "in"; "out ${"mid ${x}"}"`, "out mid in"},
+		{`"${if (false) { 1 }}"`, "null"},
+		{`"\${x}"`, "${x}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String, got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value, got=%q, want=%q", str.Value, tt.expected)
+		}
+	}
+
+	evaluated := checkEval(`"${foo}"`)
+	if err, ok := evaluated.(*object.Error); !ok || err.Message != "Identifier not found: foo" {
+		t.Errorf("expected identifier error, got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + ", " + "world"`
 	evaluated := checkEval(input)
@@ -382,7 +505,13 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(1)`, "Argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "Wrong number of arguments, got=2, want=1"},
+		{`keys()`, "Wrong number of arguments, got=0, want=1"},
+		{`values({}, {})`, "Wrong number of arguments, got=2, want=1"},
+		{`keys([])`, "Argument to `keys` must be MAP, got ARRAY"},
 		{`len([1, 2, 3])`, 3},
+		{`len("中文")`, 2},
+		{`len("\u{1F600}!")`, 2},
+		{`名字 = "火焰"; len(名字)`, 2},
 	}
 
 	for _, tt := range tests {
@@ -575,6 +704,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -589,6 +726,72 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello"[0]`, "h"},
+		{`"hello"[4]`, "o"},
+		{`"hello"[-1]`, "o"},
+		{`"hello"[5]`, nil},
+		{`"hello"[-6]`, nil},
+		{`"中文字符"[1]`, "文"},
+		{`"中文字符"[-1]`, "符"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+
+		if expected, ok := tt.expected.(string); ok {
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String, got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value, got=%q, want=%q", str.Value, expected)
+			}
+		} else if !checkNullObject(t, evaluated) {
+			t.Errorf("object is not NULL, got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1, 3, 5]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5, 4, 3, 2, 1]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:-2]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4, 5][4:1:-2]", "[5, 3]"},
+		{"[1, 2, 3, 4, 5][-100:100]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][3:1]", "[]"},
+		{"[1, 2, 3][0:2 ** 64]", "[1, 2, 3]"},
+		{"(1, 2, 3)[1:]", "(2, 3)"},
+		{`"hello"[1:4]`, `"ell"`},
+		{`"hello"[::-1]`, `"olleh"`},
+		{`"中文字符"[1:3]`, `"文字"`},
+		{"[1, 2, 3][::0]", "Slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "Slice index not support: STRING"},
+		{"{1: 2}[1:]", "Slice operator not support: MAP"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
 func TestMapLiterals(t *testing.T) {
 	input := `
 	two = "two";
@@ -616,18 +819,120 @@ func TestMapLiterals(t *testing.T) {
 		FALSE:                          6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Map has wrong num of pairs, got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Map has wrong num of pairs, got=%d", result.Len())
 	}
 
 	for expectedKey, expectedValue := range expected {
-		hashKey := expectedKey.(object.Hashable).Hash()
-		pair, ok := result.Pairs[hashKey]
+		value, ok := result.Get(expectedKey.(object.Hashable))
 		if !ok {
 			t.Errorf("no pair for given key in Pairs, key=%s", expectedKey.Inspect())
+			continue
+		}
+
+		checkIntegerObject(t, value, expectedValue)
+	}
+}
+
+func TestMapOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`{"c": 1, "a": 2, "b": 3}`,
+			`{"c": 1, "a": 2, "b": 3}`,
+		},
+		{
+			`{3: "x", 1: "y", 2: "z", 1: "w"}`,
+			`{3: "x", 1: "w", 2: "z"}`,
+		},
+		{
+			`keys({"c": 1, "a": 2, true: 3})`,
+			`["c", "a", true]`,
+		},
+		{
+			`values({"c": 1, "a": 2, true: 3})`,
+			`[1, 2, 3]`,
+		},
+		{
+			`len({"c": 1, "a": 2, "c": 3})`,
+			`2`,
+		},
+	}
+
+	for _, tt := range tests {
+		// Go randomizes map iteration, so evaluate several times to catch it
+		for i := 0; i < 10; i++ {
+			evaluated := checkEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Fatalf("wrong result for %q, got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+			}
 		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [1, 2, 3]", false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{"(1, 2) == (1, 2)", true},
+		{"(1, 2) == [1, 2]", false},
+		{"if (false) { 1 } == if (false) { 2 }", true},
+		{"[1] == 1", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		checkBooleanObject(t, evaluated, tt.expected)
+	}
+}
 
-		checkIntegerObject(t, pair.Value, expectedValue)
+func TestTupleExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"(1, 2, 3)[1]", 2},
+		{"len((1, 2, 3))", 3},
+		{"(1,)[0]", 1},
+		{"len(())", 0},
+		{`m = {(1, "a"): 1, (2, "b"): 2}; m[(2, "b")]`, 2},
+		{`m = {(1, (2, 3)): 5}; m[(1, (2, 3))]`, 5},
+		{`m = {(1, 2): 1}; m[(2, 1)]`, nil},
+		{`{(1, [2]): 1}`, "unusable as map key: TUPLE"},
+		{`{[1, 2]: 1}`, "unusable as map key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			checkIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			if !checkNullObject(t, evaluated) {
+				t.Errorf("object is not NULL, got=%T (%+v)", evaluated, evaluated)
+			}
+		}
 	}
 }
 
@@ -894,3 +1199,786 @@ func TestExpandMacros(t *testing.T) {
 		}
 	}
 }
+
+func TestElseIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"x = -5; if (x < 0) { 1 } else if (x == 0) { 2 } else { 3 }", 1},
+		{"x = 0; if (x < 0) { 1 } else if (x == 0) { 2 } else { 3 }", 2},
+		{"x = 5; if (x < 0) { 1 } else if (x == 0) { 2 } else { 3 }", 3},
+		{"x = 5; if (x < 0) { 1 } else if (x == 0) { 2 }", nil},
+		{"x = 5; if (x < 0) { 1 } else if (x == 0) { 2 } else if (x == 5) { 4 } else { 3 }", 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if integer, ok := tt.expected.(int); ok {
+			checkIntegerObject(t, evaluated, int64(integer))
+		} else {
+			checkNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	classify := `f = |x| {
+		match x {
+			0 => 100,
+			1..10 => 200,
+			10..=20 => 300,
+			"one" => 1,
+			[] => 0,
+			[a] => a,
+			[a, b, ..rest] if len(rest) > 0 => a + b + len(rest),
+			[a, b] => a * b,
+			{"k": v} => v,
+			true => 400,
+			_ => -1
+		}
+	};`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{classify + "f(0)", 100},
+		{classify + "f(5)", 200},
+		{classify + "f(10)", 300},
+		{classify + "f(20)", 300},
+		{classify + "f(21)", -1},
+		{classify + `f("one")`, 1},
+		{classify + "f([])", 0},
+		{classify + "f([7])", 7},
+		{classify + "f([2, 3])", 6},
+		{classify + "f([2, 3, 4, 5])", 7},
+		{classify + `f({"k": 9, "j": 1})`, 9},
+		{classify + `f({"j": 1})`, -1},
+		{classify + "f(true)", 400},
+		{classify + "f(false)", -1},
+		{"match 3 { 1 => 1, 2 => 2 }", nil},
+		{"match [1, [2, 3]] { [a, [b, c]] => a + b + c }", 6},
+		{"match 5 { n if n > 10 => 1, n => { n * 2 } }", 10},
+		{"a = 1; match 5 { a => a }; a", 1},
+		{"match 5 { a => a }; a", "Identifier not found: a"},
+		{"match 5 { n if n + true => 1 }", "Type mismatch: INTEGER + BOOLEAN"},
+		{`match {"k": 1} { {[1]: v} => v }`, "unusable as map key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			checkIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q, got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message, expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			if !checkNullObject(t, evaluated) {
+				t.Errorf("object is not NULL for %q, got=%T(%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn add(x, y) { x + y }; add(2, 3)", 5},
+		{"r = add(2, 3); fn add(x, y) { x + y }; r", 5},
+		{`fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+		  fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+		  if (isEven(10)) { 1 } else { 0 }`, 1},
+		{"f = |x| { r = g(x) * 2; fn g(y) { y + 1 }; r }; f(4)", 10},
+		{"{ fn g() { 1 } }\ng()", "Identifier not found: g"},
+		{"fn add(x, y) { x + y }; add(1)", "Wrong number of arguments to `add`, got=1, want=2"},
+		{"f = |x| { x }; f(1, 2)", "Wrong number of arguments, got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			checkIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q, got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message, expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	evaluated := checkEval("fn double(x) { x * 2 }; double")
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function, got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if fn.Name != "double" {
+		t.Errorf("fn.Name is not 'double', got=%q", fn.Name)
+	}
+
+	expectedInspect := "fn double(x) {\n    (x * 2);\n}"
+	if fn.Inspect() != expectedInspect {
+		t.Errorf("fn.Inspect() wrong, expected=%q, got=%q", expectedInspect, fn.Inspect())
+	}
+
+	evaluated = checkEval("fn inner() { 1 + true }; fn outer() { inner() + 1 }; outer()")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error, got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expectedError := "Type mismatch: INTEGER + BOOLEAN\n    in fn inner\n    in fn outer"
+	if errObj.Inspect() != expectedError {
+		t.Errorf("errObj.Inspect() wrong, expected=%q, got=%q", expectedError, errObj.Inspect())
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// Deep recursion must not grow the Go stack, so keep it small to catch
+	// regressions rather than relying on the default limit
+	defer debug.SetMaxStack(debug.SetMaxStack(32 << 20))
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn count(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(1000000, 0)", 1000000},
+		{"fn count(n, acc) { if (n == 0) { return acc; }; return count(n - 1, acc + 1); }; count(100000, 0)", 100000},
+		{"fn count(n, acc) { match n { 0 => acc, _ => count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{"fn count(n, acc) { if (n == 0) { acc } else if (n > 0) { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{`fn isEven(n) { if (n == 0) { 1 } else { isOdd(n - 1) } }
+		  fn isOdd(n) { if (n == 0) { 0 } else { isEven(n - 1) } }
+		  isEven(100000)`, 1},
+		{`fn sum(list, acc) { if (len(list) == 0) { acc } else { sum(rest(list), acc + first(list)) } }
+		  sum([1, 2, 3, 4], 0)`, 10},
+		{"fn fact(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(10)", 3628800},
+		// Early returns of a call are tail calls too
+		{"fn loop(n) { if n == 0 { return 0 }\nif n > 0 { return loop(n - 1) }\n0 }\nloop(100000)", 0},
+		{"fn count(n, acc) { while true { if n == 0 { return acc }\nreturn count(n - 1, acc + 1) } }\ncount(100000, 0)", 100000},
+		{"fn count(n, acc) { for x in [n] { match x { 0 => { return acc }, _ => { return count(x - 1, acc + 1) } } } }\ncount(100000, 0)", 100000},
+		{"fn one() { 1 }\nreturn one()", 1},
+		{"fn mark(c) { send(c, 7) }\nfn gen(c) { yield 0\nreturn mark(c) }\nc = chan(1)\narray(gen(c))\nrecv(c)", 7},
+	}
+
+	for _, tt := range tests {
+		checkIntegerObject(t, checkEval(tt.input), tt.expected)
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"s = 0; for x in [1, 2, 3] { s = s + x }; s", 6},
+		{"s = 0; for x in (1, 2, 3) { if (x == 2) { continue }; s = s + x }; s", 4},
+		{"s = 0; for x in [1, 2, 3] { if (x == 2) { break }; s = s + x }; s", 1},
+		{`s = ""; for c in "héllo" { s = c + s }; len(s)`, 5},
+		{`n = 0; for k in {"a": 1, "b": 2} { n = n + 1 }; n`, 2},
+		{"f = || { for x in [1, 2, 3] { if (x == 2) { return x } } }; f()", 2},
+		{"for x in [1] { x }; x", "Identifier not found: x"},
+		{"for x in 5 { x }", "Object is not iterable: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			checkIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q, got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message, expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	naturals := "fn naturals() { n = 0; while (true) { yield n; n = n + 1 } }; "
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{naturals + "array(take(naturals(), 4))", "[0, 1, 2, 3]"},
+		{naturals + "g = naturals(); next(g); next(g); next(g)", "2"},
+		{naturals + "s = 0; for x in naturals() { if (x > 4) { break }; s = s + x }; s", "10"},
+		{"fn pair(a, b) { yield a; yield b; }; g = pair(1, 2); [next(g), done(g), next(g), done(g), next(g)]",
+			"[1, false, 2, true, null]"},
+		{"fn squares(xs) { for x in xs { yield x * x } }; [...squares([1, 2, 3])]", "[1, 4, 9]"},
+		{`fn chars(s) { for c in s { yield c } }; array(chars("ab"))`, `["a", "b"]`},
+		{"fn gen() { yield 1 }; gen", "fn gen() {\n    (yield 1);\n}"},
+		{"fn gen() { yield 1 }; gen()", "<generator gen>"},
+		{"g = || { return 5; yield 1 }; array(g())", "[]"},
+		{"fn gen() { yield 1; 1 + true }; array(gen())", "Type mismatch: INTEGER + BOOLEAN"},
+		{"fn gen() { yield 1; 1 + true }; s = 0; for x in gen() { s = s + x }", "Type mismatch: INTEGER + BOOLEAN"},
+		{naturals + "[first(naturals()), first(rest(naturals())), last(take(naturals(), 5))]", "[0, 1, 4]"},
+		{"fn gen() { yield 1; 1 + true }; last(gen())", "Type mismatch: INTEGER + BOOLEAN"},
+		{`[first(()), last("ab"), rest([]), array(rest((1, 2)))]`, `[null, "b", null, [2]]`},
+		{"first(1)", "Object is not iterable: INTEGER"},
+		{"[array(take([1, 2], 99999999999999999999)), array(take([1, 2], -99999999999999999999))]", "[[1, 2], []]"},
+		{"next([1])", "Argument to `next` must be ITERATOR, got ARRAY"},
+		{"next()", "Wrong number of arguments, got=0, want=1"},
+		{"done()", "Wrong number of arguments, got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs = [2, 3]; [1, ...xs, 4]", "[1, 2, 3, 4]"},
+		{"[...[], ...(1, 2)]", "[1, 2]"},
+		{`[..."ab"]`, `["a", "b"]`},
+		{`keys = [...{"a": 1, "b": 2}]; keys`, `["a", "b"]`},
+		{"add = |a, b, c| { a + b + c }; add(1, ...[2, 3])", "6"},
+		{"(0, ...iter([1]))", "(0, 1)"},
+		{"[...5]", "Object is not iterable: INTEGER"},
+		{"x = ...[1]", "Spread not support here: ...[1]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"c = chan(2); send(c, 1); send(c, 2); [recv(c), recv(c)]", "[1, 2]"},
+		{"c = chan(1); send(c, 1); close(c); [recv(c), recv(c)]", "[1, null]"},
+		{"c = chan(3); send(c, 1); send(c, 2); close(c); [...c]", "[1, 2]"},
+		{"fn produce(c) { for i in [1, 2, 3] { send(c, i) }; close(c) }; c = chan(); spawn produce(c); array(c)", "[1, 2, 3]"},
+		{"fn add(a, b) { a + b }; recv(spawn add(1, 2))", "3"},
+		{"fn fail() { 1 + true }; recv(spawn fail())", "Type mismatch: INTEGER + BOOLEAN\n    in fn fail"},
+		{`fn ping(src, dst) { send(dst, recv(src) + 1) }
+		  a = chan(); b = chan(); spawn ping(a, b); send(a, 41); recv(b)`, "42"},
+		// Tasks share structs and maps
+		{`struct Box { value }
+		  b = Box(0); m = {}
+		  fn fill(n) { i = 0
+		    while i < 300 { b.value = n; m.value = n; i = i + 1 }
+		    m.value = b.value }
+		  tasks = [spawn fill(1), spawn fill(1), spawn fill(1)]
+		  done = [recv(tasks[0]), recv(tasks[1]), recv(tasks[2])]
+		  values = [b.value, m.value]
+		  values`, "[1, 1]"},
+		{"c = chan(); close(c); send(c, 1)", "Send on closed channel"},
+		{"c = chan(); close(c); close(c)", "Close of closed channel"},
+		{"recv(1)", "Argument to `recv` must be CHANNEL, got INTEGER"},
+		{"send()", "Wrong number of arguments, got=0, want=2"},
+		{"recv()", "Wrong number of arguments, got=0, want=1"},
+		{"close()", "Wrong number of arguments, got=0, want=1"},
+		{"chan(-1)", "Argument to `chan` must be a non-negative INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSelectExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a = chan(); b = chan(1); send(b, 5); select { x = recv(a) => x, y = recv(b) => y * 2 }", "10"},
+		{"a = chan(); select { x = recv(a) => x, _ => 0 }", "0"},
+		{"a = chan(1); select { send(a, 3) => { recv(a) } }", "3"},
+		{"a = chan(); close(a); select { x = recv(a) => x }", "null"},
+		{`fn later(c) { send(c, "late") }; c = chan(); spawn later(c); select { x = recv(c) => x }`, `"late"`},
+		{`fn take(c) { recv(c) }; c = chan(); r = spawn take(c); select { send(c, 7) => recv(r) }`, "7"},
+		{"a = chan(); select { x = recv(a) => x }", "Deadlock: all tasks are blocked"},
+		{"select { x = recv(1) => x }", "Select case must be on CHANNEL, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDeadlockDetection(t *testing.T) {
+	tests := []string{
+		"recv(chan())",
+		"send(chan(), 1)",
+		"c = chan(1); send(c, 1); send(c, 2)",
+		"fn wait(c) { recv(c) }; c = chan(); spawn wait(c); recv(chan())",
+		"fn wait(c) { recv(c) }; c = chan(); recv(spawn wait(c))",
+		"fn gen(c) { yield recv(c) }; next(gen(chan()))",
+	}
+
+	for _, input := range tests {
+		evaluated := checkEval(input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q, got=%T(%+v)", input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != "Deadlock: all tasks are blocked" {
+			t.Errorf("wrong error message for %q, got=%q", input, errObj.Message)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firework")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib")
+	files := map[string]string{
+		"main.fw": `import "util"
+from "util" import double, twice
+from "shared" import hidden`,
+		"util.fw": `export fn double(x) { x * 2 }
+export twice = macro(e) { quote(unquote(e) + unquote(e)) }
+counter = 0`,
+		"cycle_a.fw":    `import "cycle_b"`,
+		"cycle_b.fw":    `import "cycle_a"`,
+		"lib/shared.fw": "export visible = 1\nhidden = 2",
+		"slow.fw":       "total = 0\nwhile total < 20000 { total = total + 1 }",
+		"noisy.fw":      "print(\"loading\")\nexport inc = macro(e) { quote(unquote(e) + 1) }",
+		"checked.fw":    "from \"noisy\" import inc\ninc(1)",
+		"mutual_a.fw":   "total = 0\nwhile total < 20000 { total = total + 1 }\nimport \"mutual_b\"",
+		"mutual_b.fw":   "total = 0\nwhile total < 20000 { total = total + 1 }\nimport \"mutual_a\"",
+	}
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer os.Setenv("FIREWORK_PATH", os.Getenv("FIREWORK_PATH"))
+	os.Setenv("FIREWORK_PATH", lib)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "` + dir + `/util"; util["double"](21)`, "42"},
+		{`from "` + dir + `/util" import double; double(4)`, "8"},
+		{`from "` + dir + `/util" import twice; twice(3)`, "6"},
+		{`from "` + dir + `/util" import counter; counter`, "Cannot import counter from module util"},
+		{`import "` + dir + `/util"; util["counter"]`, "Module util has no export: counter"},
+		{`from "shared" import visible; visible`, "1"},
+		{`import "` + dir + `/cycle_a"`, "Import cycle: cycle_a -> cycle_b -> cycle_a"},
+		{`import "missing"`, "Module not found: missing"},
+		// Tasks importing the same module at once wait for each other
+		{`fn load() { import "` + dir + `/slow"
+		  slow["total"] }
+		  tasks = [spawn load(), spawn load(), spawn load(), spawn load()]
+		  totals = [recv(tasks[0]), recv(tasks[1]), recv(tasks[2]), recv(tasks[3])]
+		  totals`, "[20000, 20000, 20000, 20000]"},
+		{`from "` + dir + `/main" import double; double(1)`, "Cannot import hidden from module shared"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := parser.NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		evaluated := Eval(ExpandMacros(program, env), env)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	first, errObj := LoadModule(filepath.Join(dir, "util.fw"))
+	if errObj != nil {
+		t.Fatalf("cannot load module: %s", errObj.Inspect())
+	}
+	second, _ := LoadModule(filepath.Join(dir, "util.fw"))
+	if first != second {
+		t.Errorf("module util is evaluated more than once")
+	}
+
+	// Tasks importing each other's modules at once fail rather than wait
+	// forever, which error depends on how far each one got
+	l := lexer.NewLexer(`fn load_a() { import "` + dir + `/mutual_a" }
+	fn load_b() { import "` + dir + `/mutual_b" }
+	a = spawn load_a(); b = spawn load_b()
+	results = [recv(a), recv(b)]
+	results`)
+	p := parser.NewParser()
+	p.Init(l)
+	env := object.NewEnvironment()
+	evaluated := Eval(p.ParseProgram(), env)
+	if !isError(evaluated) || !strings.HasPrefix(evaluated.Inspect(), "Deadlock") && !strings.HasPrefix(evaluated.Inspect(), "Import cycle") {
+		t.Errorf("wrong result of importing each other's modules, got=%q", evaluated.Inspect())
+	}
+
+	// Expanding a module only defines the macros of the modules it imports
+	var output bytes.Buffer
+	defer func(w io.Writer) { Output = w }(Output)
+	Output = &output
+
+	expanded, errObj := ExpandModule(filepath.Join(dir, "checked.fw"))
+	if errObj != nil {
+		t.Fatalf("cannot expand module: %s", errObj.Inspect())
+	}
+	if !strings.Contains(expanded.String(), "(1 + 1)") || output.Len() != 0 {
+		t.Errorf("wrong expansion of module checked, got=%q, printed %q", expanded.String(), output.String())
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }; Point(1, 2)", "Point { x: 1, y: 2 }"},
+		{"struct Unit {}; Unit()", "Unit {}"},
+		{"struct Point { x, y }; Point", "<struct Point>"},
+		{"struct Point { x, y }; p = Point(1, 2); p.x + p.y", "3"},
+		{"struct Point { x, y }; p = Point(1, 2); p.x = 5; p", "Point { x: 5, y: 2 }"},
+		{"struct Point { x, y }; p = Point(1, 2); q = p; q.y = 7; p.y", "7"},
+		{"struct Box { value }; b = Box(Box(1)); b.value.value = 2; b", "Box { value: Box { value: 2 } }"},
+		{"struct Point { x, y }; fn origin() { Point(0, 0) }; origin().x", "0"},
+		{"struct Point { x, y }; p = Point(1, 2); p.nmae", "Point has no field: nmae"},
+		{"struct Point { x, y }; p = Point(1, 2); p.z = 3", "Point has no field: z"},
+		{"struct Point { x, y }; Point(1)", "Wrong number of arguments to `Point`, got=1, want=2"},
+		{"x = 1; x.y", "Member access not support: INTEGER"},
+		{"x = 1; x.y = 2", "Field assignment not support: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMemberAccess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`m = {"name": "fw"}; m.name`, `"fw"`},
+		{`m = {"name": "fw"}; m.nmae`, "null"},
+		{`m = {"name": "fw"}; m.name = "firework"; m.version = 2; m`, `{"name": "firework", "version": 2}`},
+		{`m = {"f": |x| { x + 1 }}; m.f(1)`, "2"},
+		{"struct Counter { step }; c = Counter(|x| { x + 2 }); c.step(1)", "3"},
+		{`"abc".upper()`, `"ABC"`},
+		{`" a,b ".trim().split(",")`, `["a", "b"]`},
+		{`"firework".replace("fire", "water").starts_with("water")`, "true"},
+		{`[1, 2, 3].map(|x| { x * 2 }).filter(|x| { x > 2 }).join(", ")`, `"4, 6"`},
+		{"[1, 2, 3].reduce(|a, b| { a + b }, 0)", "6"},
+		{"[1, 2, 3].reverse().contains(3)", "true"},
+		{"[1, 2].push(3).len()", "3"},
+		{`{"a": 1}.keys()`, `["a"]`},
+		{"fn add(x, n) { x + n }; 1.add(2).add(3)", "6"},
+		{"fn count(n) { if n == 0 { 0 } else { n.count_down() } }; fn count_down(n) { (n - 1).count() }; 100000.count()", "0"},
+		{`"a".foo()`, "STRING has no method: foo"},
+		{"struct Point { x, y }; Point(1, 2).norm()", "Point has no method: norm"},
+		{`"a".upper(1)`, "Wrong number of arguments to `upper`, got=1, want=0"},
+		{`"a".split(1)`, "Argument to `split` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestImplAndTraits(t *testing.T) {
+	point := `struct Point { x, y }
+	impl Point {
+		fn norm(self) { self.x * self.x + self.y * self.y }
+		fn scale(self, k) { Point(self.x * k, self.y * k) }
+	}
+	`
+	shapes := `trait Shape { area }
+	struct Square { side }
+	struct Circle { r }
+	impl Shape for Square { fn area(self) { self.side * self.side } }
+	impl Shape for Circle { fn area(self) { 3 * self.r * self.r } }
+	`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{point + "Point(3, 4).norm()", "25"},
+		{point + "Point(1, 2).scale(2)", "Point { x: 2, y: 4 }"},
+		{point + "impl Point { fn sum(self) { self.x + self.y } }\nPoint(1, 2).sum() + Point(1, 2).norm()", "8"},
+		{shapes + "[Square(2), Circle(1)].map(|s| { s.area() })", "[4, 3]"},
+		{shapes + "[implements(Square(1), Shape), implements(Square, Shape), implements(1, Shape)]", "[true, false, false]"},
+		{shapes + "Shape", "<trait Shape>"},
+		{"trait Shape { area, perimeter }; struct Square { side }\nimpl Shape for Square { fn area(self) { 1 } }",
+			"Square does not implement perimeter required by Shape"},
+		{"trait Named { name }; struct Dog {}\nimpl Dog { fn name(self) { \"dog\" } }\nimpl Named for Dog {}\nDog().name()", `"dog"`},
+		{"impl Missing { fn f(self) { 1 } }", "Identifier not found: Missing"},
+		{"x = 1; impl x { fn f(self) { 1 } }", "Cannot implement methods for INTEGER"},
+		{"struct S {}; impl S { fn hash(self, other) { 1 } }", "Wrong number of parameters to protocol method `hash`, got=2, want=1"},
+		{"struct S {}; x = 1; impl x for S {}", "Not a trait: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %q", tt.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestProtocols(t *testing.T) {
+	money := `struct Money { cents }
+	impl Money {
+		fn to_string(self) { "$${self.cents / 100}.${self.cents % 100}" }
+		fn equals(self, other) { self.cents == other.cents }
+		fn hash(self) { self.cents }
+		fn compare(self, other) { self.cents - other.cents }
+	}
+	struct Countdown { n }
+	impl Countdown {
+		fn iter(self) {
+			n = self.n
+			while n > 0 { yield n; n = n - 1 }
+		}
+	}
+	`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{money + "Money(250)", "$2.50"},
+		{money + `"total: ${Money(199)}"`, `"total: $1.99"`},
+		{money + "[Money(1), Money(2)]", "[$0.1, $0.2]"},
+		{money + "Money(5) == Money(5)", "true"},
+		{money + "Money(5) != Money(6)", "true"},
+		{money + `m = {Money(5): "five"}; m[Money(5)]`, `"five"`},
+		{money + "[Money(300), Money(100), Money(200)].sort()", "[$1.0, $2.0, $3.0]"},
+		{money + "[...Countdown(3)]", "[3, 2, 1]"},
+		{money + "total = 0; for n in Countdown(4) { total = total + n }; total", "10"},
+		{"struct P { x }; P(1) == P(1)", "true"},
+		{"struct P { x }; struct Q { x }; P(1) == Q(1)", "false"},
+		{"struct P { x }; {P(1): 1}", "unusable as map key: STRUCT"},
+		{"struct K { v }\nimpl K { fn hash(self) { self.v } }\n{K(\"a\"): 1}", "Protocol method `hash` of K must return INTEGER, got STRING"},
+		{"struct K { v }\nimpl K { fn hash(self) { self.v } }\nm = {K(1): 1}\nm[(1, K(true))]", "Protocol method `hash` of K must return INTEGER, got BOOLEAN"},
+		{"struct K { v }\nimpl K { fn hash(self) { self.v + true } }\n{K(1): 1}", "Type mismatch: INTEGER + BOOLEAN\n    in fn hash"},
+		{money + "[Money(5)] == [5]", "Member access not support: INTEGER\n    in fn equals"},
+		{money + "[Money(5)].contains(5)", "Member access not support: INTEGER\n    in fn equals"},
+		{"struct Bad {}\nimpl Bad { fn to_string(self) { 1 + true } }\nBad()", "Bad {}"},
+		{"struct Bad {}\nimpl Bad { fn to_string(self) { 1 + true } }\n\"${Bad()}\"", "Type mismatch: INTEGER + BOOLEAN\n    in fn to_string"},
+		{"struct Bad {}\nimpl Bad { fn to_string(self) { 1 + true } }\nprint([Bad()])", "Type mismatch: INTEGER + BOOLEAN\n    in fn to_string"},
+		{"struct Bad {}\nimpl Bad { fn to_string(self) { 1 + true } }\n[Bad()].join(\",\")", "Type mismatch: INTEGER + BOOLEAN\n    in fn to_string"},
+		{"struct P { x }; [...P(1)]", "Object is not iterable: STRUCT"},
+		{`[3, 1, 2].sort()`, "[1, 2, 3]"},
+		{`["b", "c", "a"].sort()`, `["a", "b", "c"]`},
+		{`[1, "a"].sort()`, "Cannot compare STRING and INTEGER"},
+		{"struct P { x }; [P(2), P(1)].sort()", "Cannot compare P and P"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBigStructHashes(t *testing.T) {
+	evaluated := checkEval("struct K { v }\nimpl K { fn hash(self) { self.v } }\nkeys = [K(2 ** 64), K(2 ** 65)]\nkeys")
+	keys, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array, got=%T (%+v)", evaluated, evaluated)
+	}
+
+	first, second := keys.Elements[0].(*object.Struct).Hash(), keys.Elements[1].(*object.Struct).Hash()
+	if first.Value == 0 || first == second {
+		t.Errorf("big hashes are not told apart, got=%v and %v", first, second)
+	}
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	vector := `struct Vec { x, y }
+	impl Vec {
+		fn add(self, other) { Vec(self.x + other.x, self.y + other.y) }
+		fn mul(self, k) { Vec(self.x * k, self.y * k) }
+		fn neg(self) { Vec(-self.x, -self.y) }
+		fn lt(self, other) { self.x < other.x }
+	}
+	`
+	money := `struct Money { cents }
+	impl Money {
+		fn compare(self, other) { self.cents - other.cents }
+		fn equals(self, other) { other.cents == self.cents }
+	}
+	`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{vector + "Vec(1, 2) + Vec(3, 4)", "Vec { x: 4, y: 6 }"},
+		{vector + "Vec(1, 2) * 3", "Vec { x: 3, y: 6 }"},
+		{vector + "3 * Vec(1, 2)", "Vec { x: 3, y: 6 }"},
+		{vector + "-Vec(1, 2)", "Vec { x: -1, y: -2 }"},
+		{vector + "Vec(1, 2) < Vec(2, 0)", "true"},
+		{vector + "Vec(3, 0) > Vec(2, 0)", "true"},
+		{vector + "Vec(1, 1) - Vec(1, 1)", "Unknown operator: Vec - Vec"},
+		{vector + "1 - Vec(1, 1)", "Type mismatch: INTEGER - Vec"},
+		{vector + "!Vec(1, 1)", "false"},
+		{vector + "~Vec(1, 1)", "Unknown operator: ~STRUCT"},
+		{"struct P {}; -P()", "Unknown operator: -P"},
+		{money + "[Money(1) < Money(2), Money(2) <= Money(2), Money(1) > Money(2), Money(3) >= Money(2)]", "[true, true, false, true]"},
+		{money + "Money(5) == Money(5)", "true"},
+		{money + "Money(5) != Money(5)", "false"},
+		{money + "struct Cents { cents }; Cents(5) == Money(5)", "true"},
+		{money + "Money(5) == 5", "Member access not support: INTEGER\n    in fn equals"},
+		{"struct S {}; impl S { fn add(self) { 1 } }", "Wrong number of parameters to protocol method `add`, got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestResolvedScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1; f = |x| { x }; f(2); x", "1"},
+		{"fn f(x) { x = x + 1; x }; x = 1; [f(5), x]", "[6, 1]"},
+		{"fn counter() { inc = || { n = n + 1; n }; n = 0; inc }; c = counter(); c(); c()", "2"},
+		{"fn f() { g = || { n }; g() }; f()", "Identifier not found: n"},
+		{"fn f() { g = || { n }; x = g(); n = 1; x }; f()", "Identifier not found: n\n    in fn f"},
+		{"total = 0; fn add(n) { total = total + n }; add(2); add(3); total", "5"},
+		{"if false { undefined }", "Identifier not found: undefined"},
+		{"fn f() {\n if true { y = 1 }\n y\n}", "Identifier not found: y"},
+		{"s = 0; for i in [1, 2, 3] { j = i * 2; s = s + j }; s", "12"},
+		{"fn f(v) { match v { [a, b] => a * b, n => n } }; [f([2, 3]), f(4)]", "[6, 4]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := checkEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q, expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestNilLocals(t *testing.T) {
+	// Assigning the nil of an empty block still binds the local
+	if evaluated := checkEval("fn f() {}\nfn g() { x = f()\nx }\ng()"); evaluated != nil {
+		t.Errorf("expected nil, got=%q", evaluated.Inspect())
+	}
+
+	if evaluated := checkEval("fn f() {}\nfn g() { x = f()\ny = x\n1 }\ng()"); evaluated == nil || evaluated.Inspect() != "1" {
+		t.Errorf("wrong result for a nil local: %v", evaluated)
+	}
+}
+
+func checkOptimizedEval(input string, level optimizer.Level) object.Object {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	if err := prepare(program, env, level); err != nil {
+		return err
+	}
+
+	return Eval(program, env)
+}
+
+func TestOptimizedEvaluation(t *testing.T) {
+	tests := []string{
+		"9223372036854775807 + 1",
+		"(-2) ** 63 + 2 ** -1",
+		"[1 << 63, -1 << 63, -7 >> 70, 7 % -1, -7 / 2]",
+		"1 / 0",
+		"1 << -1",
+		"\"a\" <= \"b\"",
+		"[!0, !\"a\", \"a\" + \"b\" == \"ab\", 1 == \"1\"]",
+		"if false { undefined }",
+		"x = 1\nif true { x = 2 }\nx",
+		"if true { y = 2 }\ny",
+		"x = if 1 > 2 { 3 } else { 4 }; x",
+		"f = if true { |x| { x + y } }\ny = 3\nf(1)",
+		"fn f() { return g(); h(); fn g() { 2 } }; f()",
+		"fn f() { if false { 1 } }; f()",
+		"fn f() { 3\nif true { 4 } }; f()",
+		"fn f(n) { if true { if n == 0 { return 0 } }\nf(n - 1) }; f(100000)",
+		"fn f() { for x in [1, 2] { if x == 1 { continue }\nreturn x\nprint(9) }\n0 }; f()",
+		"i = 0\nwhile i < 3 { i = i + 1\nif true { continue }\ni = 10 }\ni",
+		"quote(1 + 2)",
+	}
+
+	for _, input := range tests {
+		expected := checkOptimizedEval(input, optimizer.NONE).Inspect()
+
+		for _, level := range []optimizer.Level{optimizer.FOLD, optimizer.FULL} {
+			evaluated := checkOptimizedEval(input, level)
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q at level %d, expected=%q, got=%q", input, level, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"runtime"
+
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+const GENERATOR_OBJ = "GENERATOR"
+
+// generatorKey binds the running generator in the environment of its body,
+// being a keyword it can never clash with a user variable.
+const generatorKey = "yield"
+
+// generator runs the body of a generator function on its own goroutine. Only
+// one side runs at a time: the body waits on resume while the consumer waits
+// on values, so the environment is never accessed concurrently.
+type generator struct {
+	values chan object.Object // Closed when the body returns
+	resume chan struct{}
+	closed chan struct{} // Closed when the iterator becomes unreachable
+}
+
+func (g *generator) Inspect() string         { return "generator" }
+func (g *generator) Type() object.ObjectType { return GENERATOR_OBJ }
+
+func newGenerator(function *object.Function, env *object.Environment) *object.Iterator {
+	g := &generator{
+		values: make(chan object.Object),
+		resume: make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+	env.Define(generatorKey, g)
+
+	started := false
+	iterator := object.NewIterator(generatorName(function), func() (object.Object, bool) {
+		if started {
+			g.resume <- struct{}{}
+		} else {
+			started = true
+			go g.run(function.Body, env)
+		}
+
+		value, ok := <-g.values
+		return value, ok
+	})
+
+	// Let the body unwind instead of leaking the goroutine forever
+	runtime.SetFinalizer(iterator, func(*object.Iterator) { close(g.closed) })
+
+	return iterator
+}
+
+func generatorName(function *object.Function) string {
+	if function.Name != "" {
+		return "generator " + function.Name
+	}
+
+	return "generator"
+}
+
+func (g *generator) run(body *ast.BlockStatement, env *object.Environment) {
+	defer close(g.values)
+
+	result := completeTailCall(unwrapReturnValue(Eval(body, env)))
+	if isError(result) {
+		// Report errors as the last element unless nobody listens anymore
+		select {
+		case g.values <- result:
+		case <-g.closed:
+		}
+	}
+}
+
+// yield hands value to the consumer and blocks until the next element is
+// requested. An error is returned once the generator has been dropped.
+func (g *generator) yield(value object.Object) object.Object {
+	g.values <- value
+
+	select {
+	case <-g.resume:
+		return NULL
+	case <-g.closed:
+		return newError("Generator closed")
+	}
+}
+
+func evalYieldExpression(ye *ast.YieldExpression, env *object.Environment) object.Object {
+	value := Eval(ye.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	running, _ := env.Get(generatorKey)
+	g, ok := running.(*generator)
+	if !ok {
+		return newError("Yield outside of generator")
+	}
+
+	return g.yield(value)
+}
@@ -0,0 +1,26 @@
+package evaluator
+
+import (
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+// Hook observes the evaluation, a debugger pauses it by blocking in its
+// methods. Spawned tasks and generators call it from their own goroutines.
+type Hook interface {
+	// Statement is called before each statement of a program or block is
+	// evaluated in env
+	Statement(statement ast.Statement, env *object.Environment)
+	// Enter and Leave are called around the body of each function call, env
+	// holds its arguments
+	Enter(function *object.Function, env *object.Environment)
+	Leave(function *object.Function)
+}
+
+var hook Hook
+
+// SetHook installs h, nil removes it. It must not be called while a program
+// is being evaluated.
+func SetHook(h Hook) {
+	hook = h
+}
@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+// iterate returns an iterator over obj, the returned object is non-nil if obj
+// is not iterable.
+func iterate(obj object.Object) (*object.Iterator, object.Object) {
+	if method, ok := structMethod(obj, "iter"); ok {
+		// Through the hook, calling applyFunction would make builtins an
+		// initialization loop
+		result := object.ApplyMethod(method, []object.Object{obj})
+		if isError(result) {
+			return nil, result
+		}
+
+		if result == obj {
+			return nil, newError("Method `iter` must not return its receiver")
+		}
+
+		return iterate(result)
+	}
+
+	iterable, ok := obj.(object.Iterable)
+	if !ok {
+		return nil, newError("Object is not iterable: %s", obj.Type())
+	}
+
+	return iterable.Iter(), nil
+}
+
+// collect drains an iterator into a slice, stopping at the first error.
+func collect(iterator *object.Iterator) ([]object.Object, object.Object) {
+	elements := []object.Object{}
+
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			return elements, nil
+		}
+
+		if isError(value) {
+			return nil, value
+		}
+
+		elements = append(elements, value)
+	}
+}
+
+func evalSpreadExpression(se *ast.SpreadExpression, env *object.Environment) ([]object.Object, object.Object) {
+	value := Eval(se.Value, env)
+	if isError(value) {
+		return nil, value
+	}
+
+	iterator, err := iterate(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return collect(iterator)
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iterator, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			break
+		}
+
+		if isError(value) {
+			return value
+		}
+
+		loopEnv := object.ExtendEnvironment(env)
+		loopEnv.Define(fs.Variable.Value, value)
+
+		body := Eval(fs.Body, loopEnv)
+
+		if isError(body) || isReturn(body) {
+			return body
+		}
+
+		if isBreak(body) {
+			break
+		}
+	}
+
+	return nil
+}
@@ -47,6 +47,8 @@ func convertObjectToASTNode(obj object.Object) ast.Node {
 	switch obj := obj.(type) {
 	case *object.Integer:
 		return &ast.IntegerLiteral{Value: obj.Value}
+	case *object.BigInteger:
+		return &ast.BigIntegerLiteral{Value: obj.Value}
 	case *object.Boolean:
 		return &ast.Boolean{Value: obj.Value}
 	case *object.Quote:
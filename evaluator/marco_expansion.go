@@ -6,6 +6,10 @@ import (
 )
 
 func isMarcoDefinition(statement ast.Statement) (bool, *ast.MacroLiteral, string) {
+	if exportStatement, ok := statement.(*ast.ExportStatement); ok {
+		statement = exportStatement.Statement
+	}
+
 	assignStatement, ok := statement.(*ast.AssignStatement)
 	if !ok {
 		return false, nil, ""
@@ -30,9 +34,19 @@ func addMacroDefinition(macroLiteral *ast.MacroLiteral, env *object.Environment,
 }
 
 func DefineMacros(program *ast.Program, env *object.Environment) {
+	defineMacros(program, env, loadModule)
+}
+
+// defineMacros is DefineMacros, the modules imported from are loaded by load.
+func defineMacros(program *ast.Program, env *object.Environment, load moduleLoader) {
 	definitions := []int{}
 
 	for i, statement := range program.Statements {
+		if importStatement, ok := statement.(*ast.ImportStatement); ok {
+			importMacros(importStatement, env, load)
+			continue
+		}
+
 		ok, macroLiteral, name := isMarcoDefinition(statement)
 		if ok {
 			addMacroDefinition(macroLiteral, env, name)
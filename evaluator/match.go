@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment, tail bool) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		// Each arm binds its pattern variables in a scope of its own
+		armEnv := object.ExtendEnvironment(env)
+
+		matched, err := matchPattern(arm.Pattern, subject, armEnv)
+		if err != nil {
+			return err
+		}
+
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return evalBranch(arm.Body, armEnv, tail)
+	}
+
+	return NULL
+}
+
+// matchPattern reports whether value matches pattern, binding pattern
+// variables in env. The returned object is non-nil on evaluation errors.
+func matchPattern(pattern ast.Pattern, value object.Object, env *object.Environment) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true, nil
+	case *ast.BindingPattern:
+		env.Define(pattern.Name.Value, value)
+		return true, nil
+	case *ast.LiteralPattern:
+		literal := Eval(pattern.Value, env)
+		if isError(literal) {
+			return false, literal
+		}
+
+		return object.Equal(literal, value)
+	case *ast.RangePattern:
+		return matchRangePattern(pattern, value, env)
+	case *ast.ArrayPattern:
+		return matchArrayPattern(pattern, value, env)
+	case *ast.MapPattern:
+		return matchMapPattern(pattern, value, env)
+	default:
+		return false, newError("Unknown pattern: %s", pattern.String())
+	}
+}
+
+func matchRangePattern(pattern *ast.RangePattern, value object.Object, env *object.Environment) (bool, object.Object) {
+	low := Eval(pattern.Low, env)
+	if isError(low) {
+		return false, low
+	}
+
+	high := Eval(pattern.High, env)
+	if isError(high) {
+		return false, high
+	}
+
+	// Values of other types never match instead of being a type mismatch
+	if value.Type() != low.Type() || value.Type() != high.Type() {
+		return false, nil
+	}
+
+	aboveLow := evalInfixExpression(">=", value, low)
+	if isError(aboveLow) {
+		return false, aboveLow
+	}
+
+	upperOperator := "<"
+	if pattern.Inclusive {
+		upperOperator = "<="
+	}
+
+	belowHigh := evalInfixExpression(upperOperator, value, high)
+	if isError(belowHigh) {
+		return false, belowHigh
+	}
+
+	return isTruthy(aboveLow) && isTruthy(belowHigh), nil
+}
+
+func matchArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) (bool, object.Object) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return false, nil
+	}
+
+	length := len(pattern.Elements)
+	if len(array.Elements) < length || (!pattern.HasRest && len(array.Elements) != length) {
+		return false, nil
+	}
+
+	for i, element := range pattern.Elements {
+		matched, err := matchPattern(element, array.Elements[i], env)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := make([]object.Object, len(array.Elements)-length)
+		copy(rest, array.Elements[length:])
+		env.Define(pattern.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return true, nil
+}
+
+func matchMapPattern(pattern *ast.MapPattern, value object.Object, env *object.Environment) (bool, object.Object) {
+	mapObject, ok := value.(*object.Map)
+	if !ok {
+		return false, nil
+	}
+
+	for _, pair := range pattern.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return false, key
+		}
+
+		hashableKey, err := asMapKey(key)
+		if err != nil {
+			return false, err
+		}
+
+		element, ok := mapObject.Get(hashableKey)
+		if !ok {
+			return false, nil
+		}
+
+		matched, err := matchPattern(pair.Pattern, element, env)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
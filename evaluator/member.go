@@ -0,0 +1,363 @@
+package evaluator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+// methods are looked up by the type of the receiver, which is passed to them
+// as the first argument.
+var methods map[object.ObjectType]map[string]*object.Builtin
+
+func init() {
+	// Set in init as the methods calling back into functions would otherwise
+	// form an initialization loop with Eval
+	methods = map[object.ObjectType]map[string]*object.Builtin{
+		object.STRING_OBJ: stringMethods,
+		object.ARRAY_OBJ:  arrayMethods(),
+	}
+
+	object.ApplyMethod = applyFunction
+}
+
+// checkMethodArguments returns an error unless the method got the receiver
+// and want other arguments.
+func checkMethodArguments(name string, args []object.Object, want int) object.Object {
+	if len(args)-1 != want {
+		return newError("Wrong number of arguments to `%s`, got=%d, want=%d", name, len(args)-1, want)
+	}
+	return nil
+}
+
+func stringArgument(method string, arg object.Object) (string, object.Object) {
+	str, ok := arg.(*object.String)
+	if !ok {
+		return "", newError("Argument to `%s` must be STRING, got %s", method, arg.Type())
+	}
+	return str.Value, nil
+}
+
+// stringMethod adapts a function of the receiver and arity string arguments.
+func stringMethod(name string, arity int, fn func(receiver string, args []string) object.Object) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkMethodArguments(name, args, arity); err != nil {
+				return err
+			}
+
+			values := []string{}
+			for _, arg := range args {
+				value, err := stringArgument(name, arg)
+				if err != nil {
+					return err
+				}
+				values = append(values, value)
+			}
+
+			return fn(values[0], values[1:])
+		},
+	}
+}
+
+func newString(value string) object.Object {
+	return &object.String{Value: value}
+}
+
+var stringMethods = map[string]*object.Builtin{
+	"upper": stringMethod("upper", 0, func(s string, _ []string) object.Object {
+		return newString(strings.ToUpper(s))
+	}),
+	"lower": stringMethod("lower", 0, func(s string, _ []string) object.Object {
+		return newString(strings.ToLower(s))
+	}),
+	"trim": stringMethod("trim", 0, func(s string, _ []string) object.Object {
+		return newString(strings.TrimSpace(s))
+	}),
+	"contains": stringMethod("contains", 1, func(s string, args []string) object.Object {
+		return nativeBoolToBooleanObject(strings.Contains(s, args[0]))
+	}),
+	"starts_with": stringMethod("starts_with", 1, func(s string, args []string) object.Object {
+		return nativeBoolToBooleanObject(strings.HasPrefix(s, args[0]))
+	}),
+	"ends_with": stringMethod("ends_with", 1, func(s string, args []string) object.Object {
+		return nativeBoolToBooleanObject(strings.HasSuffix(s, args[0]))
+	}),
+	"replace": stringMethod("replace", 2, func(s string, args []string) object.Object {
+		return newString(strings.ReplaceAll(s, args[0], args[1]))
+	}),
+	"split": stringMethod("split", 1, func(s string, args []string) object.Object {
+		parts := strings.Split(s, args[0])
+		elements := make([]object.Object, len(parts))
+		for i, part := range parts {
+			elements[i] = newString(part)
+		}
+		return &object.Array{Elements: elements}
+	}),
+}
+
+func arrayMethods() map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"map": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkMethodArguments("map", args, 1); err != nil {
+					return err
+				}
+
+				elements := []object.Object{}
+				for _, element := range args[0].(*object.Array).Elements {
+					mapped := applyFunction(args[1], []object.Object{element})
+					if isError(mapped) {
+						return mapped
+					}
+					elements = append(elements, mapped)
+				}
+
+				return &object.Array{Elements: elements}
+			},
+		},
+		"filter": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkMethodArguments("filter", args, 1); err != nil {
+					return err
+				}
+
+				elements := []object.Object{}
+				for _, element := range args[0].(*object.Array).Elements {
+					keep := applyFunction(args[1], []object.Object{element})
+					if isError(keep) {
+						return keep
+					}
+
+					if isTruthy(keep) {
+						elements = append(elements, element)
+					}
+				}
+
+				return &object.Array{Elements: elements}
+			},
+		},
+		"reduce": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkMethodArguments("reduce", args, 2); err != nil {
+					return err
+				}
+
+				accumulated := args[2]
+				for _, element := range args[0].(*object.Array).Elements {
+					accumulated = applyFunction(args[1], []object.Object{accumulated, element})
+					if isError(accumulated) {
+						return accumulated
+					}
+				}
+
+				return accumulated
+			},
+		},
+		"contains": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkMethodArguments("contains", args, 1); err != nil {
+					return err
+				}
+
+				for _, element := range args[0].(*object.Array).Elements {
+					equal, err := object.Equal(element, args[1])
+					if err != nil {
+						return err
+					}
+					if equal {
+						return TRUE
+					}
+				}
+
+				return FALSE
+			},
+		},
+		"reverse": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkMethodArguments("reverse", args, 0); err != nil {
+					return err
+				}
+
+				elements := args[0].(*object.Array).Elements
+				reversed := make([]object.Object, len(elements))
+				for i, element := range elements {
+					reversed[len(elements)-1-i] = element
+				}
+
+				return &object.Array{Elements: reversed}
+			},
+		},
+		"sort": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkMethodArguments("sort", args, 0); err != nil {
+					return err
+				}
+
+				elements := args[0].(*object.Array).Elements
+				sorted := make([]object.Object, len(elements))
+				copy(sorted, elements)
+
+				var failure object.Object
+				sort.SliceStable(sorted, func(i, j int) bool {
+					if failure != nil {
+						return false
+					}
+
+					order, err := compare(sorted[i], sorted[j])
+					if err != nil {
+						failure = err
+					}
+					return order < 0
+				})
+
+				if failure != nil {
+					return failure
+				}
+
+				return &object.Array{Elements: sorted}
+			},
+		},
+		"join": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkMethodArguments("join", args, 1); err != nil {
+					return err
+				}
+
+				separator, err := stringArgument("join", args[1])
+				if err != nil {
+					return err
+				}
+
+				parts := []string{}
+				for _, element := range args[0].(*object.Array).Elements {
+					part, err := toDisplayString(element)
+					if err != nil {
+						return err
+					}
+					parts = append(parts, part)
+				}
+
+				return newString(strings.Join(parts, separator))
+			},
+		},
+	}
+}
+
+// lookupMember returns the field of a struct, the value of a string key in a
+// map or an export of a module.
+func lookupMember(obj object.Object, name string) (object.Object, bool) {
+	switch obj := obj.(type) {
+	case *object.Struct:
+		return obj.Get(name)
+	case *object.Map:
+		return obj.Get(&object.String{Value: name})
+	case *object.Module:
+		return obj.Get(name)
+	default:
+		return nil, false
+	}
+}
+
+func evalMemberExpression(me *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(me.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	if value, ok := lookupMember(obj, me.Member.Value); ok {
+		return value
+	}
+
+	switch obj := obj.(type) {
+	case *object.Struct:
+		return newError("%s has no field: %s", obj.Definition.Name, me.Member.Value)
+	case *object.Map:
+		return NULL
+	case *object.Module:
+		return newError("Module %s has no export: %s", obj.Name, me.Member.Value)
+	default:
+		return newError("Member access not support: %s", obj.Type())
+	}
+}
+
+// evalMethodCall resolves receiver.name(args) to the function to call and
+// its arguments. A member holding a function is called with args only,
+// otherwise the receiver is passed first to a method of its type, or to the
+// function name in scope.
+func evalMethodCall(me *ast.MemberExpression, arguments []ast.Expression, env *object.Environment) (object.Object, []object.Object) {
+	receiver := Eval(me.Object, env)
+	if isError(receiver) {
+		return receiver, nil
+	}
+
+	name := me.Member.Value
+	function, isMember := lookupMember(receiver, name)
+
+	if !isMember {
+		if method, ok := structMethod(receiver, name); ok {
+			function = method
+		} else if method, ok := methods[receiver.Type()][name]; ok {
+			function = method
+		} else if value, ok := env.Get(name); ok {
+			function = value
+		} else if builtin, ok := builtins[name]; ok {
+			function = builtin
+		} else {
+			return newError("%s has no method: %s", typeName(receiver), name), nil
+		}
+	}
+
+	args := evalExpressions(arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0], nil
+	}
+
+	if isMember {
+		return function, args
+	}
+
+	return function, append([]object.Object{receiver}, args...)
+}
+
+// structMethod returns a method added to the type of obj by impl blocks.
+func structMethod(obj object.Object, name string) (object.Object, bool) {
+	s, ok := obj.(*object.Struct)
+	if !ok {
+		return nil, false
+	}
+
+	method, ok := s.Definition.Methods[name]
+	return method, ok
+}
+
+// evalCallee evaluates the function and the arguments of a call. On errors
+// the function is the error.
+func evalCallee(node *ast.CallExpression, env *object.Environment) (object.Object, []object.Object) {
+	if member, ok := node.Function.(*ast.MemberExpression); ok {
+		return evalMethodCall(member, node.Arguments, env)
+	}
+
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return function, nil
+	}
+
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0], nil
+	}
+
+	return function, args
+}
+
+// typeName is the name of the type of obj in messages, structs are named
+// after their declaration.
+func typeName(obj object.Object) string {
+	if s, ok := obj.(*object.Struct); ok {
+		return s.Definition.Name
+	}
+	return string(obj.Type())
+}
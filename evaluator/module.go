@@ -0,0 +1,316 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/lexer"
+	"github.com/vita-dounai/Firework/object"
+	"github.com/vita-dounai/Firework/optimizer"
+	"github.com/vita-dounai/Firework/parser"
+)
+
+const MODULE_EXTENSION = ".fw"
+
+// moduleKey binds the module being evaluated in its own environment, being a
+// keyword it can never clash with a user variable.
+const moduleKey = "import"
+
+// OptimizationLevel is applied to every module loaded.
+var OptimizationLevel = optimizer.NONE
+
+var modules = struct {
+	sync.Mutex
+	cache map[string]*object.Module
+	// Closed once the module being loaded from a path is evaluated, tasks
+	// importing it meanwhile wait for it. Being channels of the scheduler,
+	// tasks waiting for each other's modules are a deadlock.
+	loading map[string]*object.Channel
+}{cache: map[string]*object.Module{}, loading: map[string]*object.Channel{}}
+
+// ModuleOf returns the module code evaluated in env belongs to, nil for code
+// read by the REPL.
+func ModuleOf(env *object.Environment) *object.Module {
+	if obj, ok := env.Get(moduleKey); ok {
+		if module, ok := obj.(*object.Module); ok {
+			return module
+		}
+	}
+
+	return nil
+}
+
+// currentDirectory returns the directory imports made from env are resolved
+// against: the directory of the enclosing module, or the working directory.
+func currentDirectory(env *object.Environment) string {
+	if module := ModuleOf(env); module != nil {
+		return filepath.Dir(module.Path)
+	}
+
+	return "."
+}
+
+// resolveModule finds the file of the module imported as path, looking in dir
+// and then in every directory listed in FIREWORK_PATH.
+func resolveModule(path string, dir string) (string, bool) {
+	if filepath.Ext(path) != MODULE_EXTENSION {
+		path += MODULE_EXTENSION
+	}
+
+	candidates := []string{}
+	if filepath.IsAbs(path) {
+		candidates = append(candidates, path)
+	} else {
+		candidates = append(candidates, filepath.Join(dir, path))
+		for _, searchDir := range filepath.SplitList(os.Getenv("FIREWORK_PATH")) {
+			if searchDir != "" {
+				candidates = append(candidates, filepath.Join(searchDir, path))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			absolute, err := filepath.Abs(candidate)
+			if err != nil {
+				return candidate, true
+			}
+			return absolute, true
+		}
+	}
+
+	return "", false
+}
+
+func moduleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), MODULE_EXTENSION)
+}
+
+// LoadModule evaluates the file at path in an environment of its own, unless
+// it has been loaded already. The returned object is non-nil on errors.
+func LoadModule(path string) (*object.Module, object.Object) {
+	return loadModule(path, nil)
+}
+
+// moduleLoader loads the module at path for an import made while loading the
+// modules at importers, outermost first.
+type moduleLoader func(path string, importers []string) (*object.Module, object.Object)
+
+func importCycle(path string, importers []string) object.Object {
+	for i, importer := range importers {
+		if importer == path {
+			cycle := []string{}
+			for _, p := range importers[i:] {
+				cycle = append(cycle, moduleName(p))
+			}
+			cycle = append(cycle, moduleName(path))
+
+			return newError("Import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	return nil
+}
+
+// loadModule is the moduleLoader evaluating modules, each one once.
+func loadModule(path string, importers []string) (*object.Module, object.Object) {
+	if err := importCycle(path, importers); err != nil {
+		return nil, err
+	}
+
+	modules.Lock()
+	for {
+		if module, ok := modules.cache[path]; ok {
+			modules.Unlock()
+			return module, nil
+		}
+
+		loaded, ok := modules.loading[path]
+		if !ok {
+			break
+		}
+
+		// Another task is loading it, it is loaded again if that fails
+		modules.Unlock()
+		if _, _, err := loaded.Recv(); err != nil {
+			return nil, newError("%s", err)
+		}
+		modules.Lock()
+	}
+
+	loaded := object.NewChannel(0)
+	modules.loading[path] = loaded
+	modules.Unlock()
+
+	module, err := evalModule(path, importers)
+
+	modules.Lock()
+	delete(modules.loading, path)
+	if err == nil {
+		modules.cache[path] = module
+	}
+	modules.Unlock()
+	loaded.Close()
+
+	return module, err
+}
+
+// RunModule evaluates the file at path like LoadModule, even if it has been
+// loaded before.
+func RunModule(path string) (*object.Module, object.Object) {
+	modules.Lock()
+	delete(modules.cache, path)
+	modules.Unlock()
+
+	return LoadModule(path)
+}
+
+func evalModule(path string, importers []string) (*object.Module, object.Object) {
+	module, program, err := expandModule(path, importers, loadModule)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := prepare(program, module.Env, OptimizationLevel); err != nil {
+		return nil, err
+	}
+
+	if result := Eval(program, module.Env); isError(result) {
+		return nil, result
+	}
+
+	return module, nil
+}
+
+// prepare resolves and optimizes a macro expanded program to be evaluated in
+// env.
+func prepare(program *ast.Program, env *object.Environment, level optimizer.Level) object.Object {
+	// Errors are reported for the code as written, even if unreachable
+	if err := Resolve(program, env); err != nil {
+		return err
+	}
+
+	if level > optimizer.NONE {
+		optimizer.Optimize(program, level)
+		// The frames of the optimized code are laid out again
+		Resolve(program, env)
+	}
+
+	return nil
+}
+
+// ExpandModule parses the file at path and expands its macros without
+// evaluating it, nor the modules it imports.
+func ExpandModule(path string) (*ast.Program, object.Object) {
+	_, program, err := expandModule(path, nil, loadMacros)
+	return program, err
+}
+
+// loadMacros is the moduleLoader which only defines the macros of modules,
+// their other names are left unbound.
+func loadMacros(path string, importers []string) (*object.Module, object.Object) {
+	if err := importCycle(path, importers); err != nil {
+		return nil, err
+	}
+
+	module, _, err := expandModule(path, importers, loadMacros)
+	return module, err
+}
+
+func expandModule(path string, importers []string, load moduleLoader) (*object.Module, *ast.Program, object.Object) {
+	name := moduleName(path)
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, newError("Cannot read module %s: %s", name, err)
+	}
+
+	l := lexer.NewLexer(string(source))
+	p := parser.NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		parseError := p.Errors()[0]
+		return nil, nil, newError("Cannot parse module %s: %s: %s", name, parseError.Type(), parseError.Info())
+	}
+
+	module := &object.Module{Name: name, Path: path, Env: object.NewEnvironment(), Importers: importers}
+	for _, statement := range program.Statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			if module.Exports == nil {
+				module.Exports = map[string]bool{}
+			}
+			module.Exports[export.ExportedName()] = true
+		}
+	}
+	module.Env.Define(moduleKey, module)
+
+	defineMacros(program, module.Env, load)
+	expanded := ExpandMacros(program, module.Env)
+
+	return module, expanded.(*ast.Program), nil
+}
+
+func importModule(path string, env *object.Environment, load moduleLoader) (*object.Module, object.Object) {
+	resolved, ok := resolveModule(path, currentDirectory(env))
+	if !ok {
+		return nil, newError("Module not found: %s", path)
+	}
+
+	// Only a module importing itself through this chain is a cycle, other
+	// tasks may be loading modules at the same time
+	var importers []string
+	if importer := ModuleOf(env); importer != nil {
+		importers = append(importer.Importers[:len(importer.Importers):len(importer.Importers)], importer.Path)
+	}
+
+	return load(resolved, importers)
+}
+
+func evalImportStatement(is *ast.ImportStatement, env *object.Environment) object.Object {
+	module, err := importModule(is.Path, env, loadModule)
+	if err != nil {
+		return err
+	}
+
+	if is.Names == nil {
+		env.Set(moduleName(is.Path), module)
+		return nil
+	}
+
+	for _, name := range is.Names {
+		value, ok := module.Get(name.Value)
+		if !ok {
+			return newError("Cannot import %s from module %s", name.Value, module.Name)
+		}
+
+		env.Set(name.Value, value)
+	}
+
+	return nil
+}
+
+// importMacros binds the macros listed by a `from` import while macros are
+// being defined, so they can be expanded in the importing program. Errors are
+// left to be reported when the import statement itself is evaluated.
+func importMacros(is *ast.ImportStatement, env *object.Environment, load moduleLoader) {
+	if is.Names == nil {
+		return
+	}
+
+	module, err := importModule(is.Path, env, load)
+	if err != nil {
+		return
+	}
+
+	for _, name := range is.Names {
+		if macro, ok := module.Get(name.Value); ok && macro.Type() == object.MACRO_OBJ {
+			env.Set(name.Value, macro)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"github.com/vita-dounai/Firework/object"
+)
+
+// operatorMethods are the methods a struct type implements to overload an
+// infix operator.
+var operatorMethods = map[string]string{
+	"+":  "add",
+	"-":  "sub",
+	"*":  "mul",
+	"/":  "div",
+	"%":  "mod",
+	"**": "pow",
+	"<":  "lt",
+	"<=": "le",
+	">":  "gt",
+	">=": "ge",
+}
+
+// Operators whose operands can be swapped, so that the method of the right
+// operand is used when the left one has none.
+var commutativeOperators = map[string]bool{"+": true, "*": true}
+
+// The operator with swapped operands for comparisons, `a < b` is `b > a`.
+var swappedOperators = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+func isStruct(obj object.Object) bool {
+	_, ok := obj.(*object.Struct)
+	return ok
+}
+
+// evalOverloadedInfixExpression applies the operator method of a struct
+// operand, trying the left operand first. ok is false if neither has one.
+func evalOverloadedInfixExpression(operator string, left, right object.Object) (object.Object, bool) {
+	if !isStruct(left) && !isStruct(right) {
+		return nil, false
+	}
+
+	if operator == "==" || operator == "!=" {
+		return evalOverloadedEquality(operator, left, right)
+	}
+
+	name, ok := operatorMethods[operator]
+	if !ok {
+		return nil, false
+	}
+
+	if method, ok := structMethod(left, name); ok {
+		return applyFunction(method, []object.Object{left, right}), true
+	}
+
+	swapped, isComparison := swappedOperators[operator]
+	if isComparison {
+		if _, ok := structMethod(left, "compare"); ok {
+			return evalComparison(operator, left, right), true
+		}
+	}
+
+	if commutativeOperators[operator] {
+		if method, ok := structMethod(right, name); ok {
+			return applyFunction(method, []object.Object{right, left}), true
+		}
+	}
+
+	if isComparison {
+		if method, ok := structMethod(right, operatorMethods[swapped]); ok {
+			return applyFunction(method, []object.Object{right, left}), true
+		}
+
+		if _, ok := structMethod(right, "compare"); ok {
+			return evalComparison(swapped, right, left), true
+		}
+	}
+
+	return nil, false
+}
+
+// evalOverloadedEquality calls the equals method of either operand, unlike
+// object.Equals it reports the errors raised by the method.
+func evalOverloadedEquality(operator string, left, right object.Object) (object.Object, bool) {
+	for _, operands := range [][]object.Object{{left, right}, {right, left}} {
+		method, ok := structMethod(operands[0], "equals")
+		if !ok {
+			continue
+		}
+
+		result := applyFunction(method, operands)
+		if isError(result) {
+			return result, true
+		}
+
+		return nativeBoolToBooleanObject(isTruthy(result) == (operator == "==")), true
+	}
+
+	return nil, false
+}
+
+// evalComparison compares left and right with the compare method of left.
+func evalComparison(operator string, left, right object.Object) object.Object {
+	order, err := compare(left, right)
+	if err != nil {
+		return err
+	}
+
+	switch operator {
+	case "<":
+		return nativeBoolToBooleanObject(order < 0)
+	case "<=":
+		return nativeBoolToBooleanObject(order <= 0)
+	case ">":
+		return nativeBoolToBooleanObject(order > 0)
+	default:
+		return nativeBoolToBooleanObject(order >= 0)
+	}
+}
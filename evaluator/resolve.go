@@ -0,0 +1,39 @@
+package evaluator
+
+import (
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+	"github.com/vita-dounai/Firework/resolver"
+)
+
+// Resolve binds the local variables of program to slots, it is run after
+// macro expansion. Names defined in env or builtins are taken to be globals.
+// The returned error reports the first undefined variable.
+func Resolve(program *ast.Program, env *object.Environment) object.Object {
+	return resolve(program, env, false)
+}
+
+// ResolveInput resolves a program read by the REPL, whose functions may use
+// globals defined by later input.
+func ResolveInput(program *ast.Program, env *object.Environment) object.Object {
+	return resolve(program, env, true)
+}
+
+func resolve(program *ast.Program, env *object.Environment, interactive bool) object.Object {
+	r := resolver.NewResolver(func(name string) bool {
+		if _, ok := builtins[name]; ok {
+			return true
+		}
+
+		_, ok := env.Get(name)
+		return ok
+	})
+	r.Interactive = interactive
+	r.Resolve(program)
+
+	if len(r.Errors()) != 0 {
+		return newError("%s", r.Errors()[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+func evalStructStatement(ss *ast.StructStatement, env *object.Environment) object.Object {
+	fields := []string{}
+	for _, field := range ss.Fields {
+		fields = append(fields, field.Value)
+	}
+
+	definition := object.NewStructType(ss.Name.Value, fields)
+	env.Define(definition.Name, definition)
+	return nil
+}
+
+// protocols are the methods used by Inspect, `==`, map keys, iteration, sort
+// and operators, with their number of parameters including the receiver.
+var protocols = map[string]int{
+	"to_string": 1,
+	"equals":    2,
+	"hash":      1,
+	"iter":      1,
+	"compare":   2,
+	"neg":       1,
+}
+
+func init() {
+	for _, method := range operatorMethods {
+		protocols[method] = 2
+	}
+}
+
+func evalTraitStatement(ts *ast.TraitStatement, env *object.Environment) object.Object {
+	trait := &object.Trait{Name: ts.Name.Value, Methods: []string{}}
+	for _, method := range ts.Methods {
+		trait.Methods = append(trait.Methods, method.Value)
+	}
+
+	env.Define(trait.Name, trait)
+	return nil
+}
+
+func evalImplStatement(is *ast.ImplStatement, env *object.Environment) object.Object {
+	obj := evalIdentifier(is.Type, env)
+	if isError(obj) {
+		return obj
+	}
+
+	definition, ok := obj.(*object.StructType)
+	if !ok {
+		return newError("Cannot implement methods for %s", obj.Type())
+	}
+
+	methods := map[string]object.Object{}
+	for _, literal := range is.Methods {
+		if want, ok := protocols[literal.Name]; ok && len(literal.Parameters) != want {
+			return newError("Wrong number of parameters to protocol method `%s`, got=%d, want=%d",
+				literal.Name, len(literal.Parameters), want)
+		}
+
+		methods[literal.Name] = newFunction(literal, env)
+	}
+
+	var trait *object.Trait
+	if is.Trait != nil {
+		obj := evalIdentifier(is.Trait, env)
+		if isError(obj) {
+			return obj
+		}
+
+		trait, ok = obj.(*object.Trait)
+		if !ok {
+			return newError("Not a trait: %s", obj.Type())
+		}
+
+		for _, name := range trait.Methods {
+			_, defined := methods[name]
+			_, inherited := definition.Methods[name]
+			if !defined && !inherited {
+				return newError("%s does not implement %s required by %s", definition.Name, name, trait.Name)
+			}
+		}
+	}
+
+	for name, method := range methods {
+		definition.Methods[name] = method
+	}
+
+	if trait != nil {
+		definition.Traits[trait] = true
+	}
+
+	return nil
+}
+
+func constructStruct(definition *object.StructType, args []object.Object) object.Object {
+	if len(args) != len(definition.Fields) {
+		return newError("Wrong number of arguments to `%s`, got=%d, want=%d",
+			definition.Name, len(args), len(definition.Fields))
+	}
+
+	return object.NewStruct(definition, args)
+}
+
+func evalFieldAssignStatement(fas *ast.FieldAssignStatement, env *object.Environment) object.Object {
+	obj := Eval(fas.Target.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	name := fas.Target.Member.Value
+	switch obj := obj.(type) {
+	case *object.Struct:
+		if !obj.Definition.HasField(name) {
+			return newError("%s has no field: %s", obj.Definition.Name, name)
+		}
+	case *object.Map:
+	default:
+		return newError("Field assignment not support: %s", obj.Type())
+	}
+
+	value := Eval(fas.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	switch obj := obj.(type) {
+	case *object.Struct:
+		obj.Set(name, value)
+	case *object.Map:
+		obj.Set(&object.String{Value: name}, value)
+	}
+
+	return nil
+}
+
+// compare orders integers, strings and structs with a compare method, which
+// returns a negative, zero or positive integer.
+func compare(left, right object.Object) (int, object.Object) {
+	if method, ok := structMethod(left, "compare"); ok {
+		result := applyFunction(method, []object.Object{left, right})
+		if isError(result) {
+			return 0, result
+		}
+
+		order, ok := result.(*object.Integer)
+		if !ok {
+			return 0, newError("Method `compare` must return INTEGER, got %s", result.Type())
+		}
+
+		switch {
+		case order.Value < 0:
+			return -1, nil
+		case order.Value > 0:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	}
+
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return object.ToBigInt(left).Cmp(object.ToBigInt(right)), nil
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return strings.Compare(left.(*object.String).Value, right.(*object.String).Value), nil
+	default:
+		return 0, newError("Cannot compare %s and %s", typeName(left), typeName(right))
+	}
+}
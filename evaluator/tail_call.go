@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/object"
+)
+
+const TAIL_CALL_OBJ = "TAIL_CALL"
+
+// tailCall is a function call in tail position which has not been applied
+// yet, it never escapes applyFunction.
+type tailCall struct {
+	function *object.Function
+	args     []object.Object
+}
+
+func (tc *tailCall) Inspect() string         { return "tail call" }
+func (tc *tailCall) Type() object.ObjectType { return TAIL_CALL_OBJ }
+
+func evalBranch(node ast.Node, env *object.Environment, tail bool) object.Object {
+	if tail {
+		return evalTail(node, env)
+	}
+
+	return Eval(node, env)
+}
+
+// completeTailCall applies a tail call returned where no function called by
+// applyFunction is returning, at the top level or from a generator.
+func completeTailCall(obj object.Object) object.Object {
+	if call, ok := obj.(*tailCall); ok {
+		return applyFunction(call.function, call.args)
+	}
+
+	return obj
+}
+
+// evalTail evaluates node in tail position of a function body, a call to a
+// Firework function found there is returned as a tailCall. Returned calls are
+// in tail position wherever the return statement is.
+func evalTail(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env, true)
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env, true)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env, true)
+	case *ast.CallExpression:
+		if name, ok := node.Function.(*ast.Identifier); ok && name.Value == "quote" {
+			return quote(node.Arguments[0], env)
+		}
+
+		function, args := evalCallee(node, env)
+		if isError(function) {
+			return function
+		}
+
+		if function, ok := function.(*object.Function); ok {
+			return &tailCall{function: function, args: args}
+		}
+
+		return applyFunction(function, args)
+	default:
+		return Eval(node, env)
+	}
+}
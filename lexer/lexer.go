@@ -2,17 +2,27 @@ package lexer
 
 import (
 	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/vita-dounai/Firework/token"
 )
 
+// Lexer walks Input rune by rune, Position and ReadPosition are byte offsets
+// into Input while columns are counted in runes.
 type Lexer struct {
 	Input        string
 	Position     int
 	ReadPosition int
-	Ch           byte
+	Ch           rune
+	chWidth      int
 	line         int
 	column       int
+
+	// Brace depth of each open `${...}`, innermost last
+	interpolations []int
 }
 
 func NewLexer(input string) *Lexer {
@@ -24,9 +34,10 @@ func NewLexer(input string) *Lexer {
 func (l *Lexer) readChar() {
 	if l.ReadPosition >= len(l.Input) {
 		l.Ch = 0
+		l.chWidth = 1
 	} else {
 		l.column++
-		l.Ch = l.Input[l.ReadPosition]
+		l.Ch, l.chWidth = utf8.DecodeRuneInString(l.Input[l.ReadPosition:])
 	}
 
 	if l.Ch == '\n' {
@@ -35,62 +46,327 @@ func (l *Lexer) readChar() {
 	}
 
 	l.Position = l.ReadPosition
-	l.ReadPosition++
+	l.ReadPosition += l.chWidth
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.ReadPosition >= len(l.Input) {
 		return 0
 	}
-	return l.Input[l.ReadPosition]
+
+	ch, _ := utf8.DecodeRuneInString(l.Input[l.ReadPosition:])
+	return ch
 }
 
 func (l *Lexer) readIdentifier() string {
 	position := l.Position
 	l.readChar()
-	for isLetter(l.Ch) || isDigit(l.Ch) || l.Ch == '_' {
+	for isLetter(l.Ch) || unicode.IsDigit(l.Ch) || l.Ch == '_' {
 		l.readChar()
 	}
 	return l.Input[position:l.Position]
 }
 
+// readNumber reads decimal, `0x`, `0o` and `0b` literals with `_` separators.
+// Trailing letters are kept so that malformed literals are reported as a whole.
 func (l *Lexer) readNumber() string {
 	position := l.Position
-	for isDigit(l.Ch) {
+	for isDigit(l.Ch) || isLetter(l.Ch) || l.Ch == '_' {
 		l.readChar()
 	}
 	return l.Input[position:l.Position]
 }
 
-func (l *Lexer) readString() string {
+func (l *Lexer) atEOF() bool {
+	return l.Position >= len(l.Input)
+}
+
+// readString reads a string literal, or the rest of one after an interpolated
+// expression when l.Ch is the closing `}` of `${...}`. A literal with
+// interpolations is split into STRING_HEAD, STRING_MIDDLE and STRING_TAIL
+// tokens around the tokens of the embedded expressions. Unterminated strings
+// produce an error token positioned at the start of the part, illegal escape
+// sequences one positioned at the backslash. It stops at the closing quote or
+// at the `{` of an interpolation.
+func (l *Lexer) readString() token.Token {
+	head := l.Ch == '"'
+	startPosition := l.Position
+	startLine := l.line
+	startColumn := l.column
+
 	var str bytes.Buffer
+	var illegalEscape *token.Token
+
 	for {
 		l.readChar()
+		if l.atEOF() {
+			return l.newToken(token.UNTERMINATED_STRING, l.Input[startPosition:], startLine, startColumn)
+		}
+
 		if l.Ch == '\\' {
+			escapeLine := l.line
+			escapeColumn := l.column
+
 			l.readChar()
+			if l.atEOF() {
+				return l.newToken(token.UNTERMINATED_STRING, l.Input[startPosition:], startLine, startColumn)
+			}
+
 			switch l.Ch {
 			case 'n':
 				str.WriteByte('\n')
 			case 't':
 				str.WriteByte('\t')
+			case 'r':
+				str.WriteByte('\r')
 			case '"':
 				str.WriteByte('"')
+			case '$':
+				str.WriteByte('$')
+			case '\\':
+				str.WriteByte('\\')
+			case 'u':
+				if ch, ok := l.readUnicodeEscape(); ok {
+					str.WriteRune(ch)
+					break
+				}
+				fallthrough
 			default:
-				str.Write([]byte{'\\', l.Ch})
+				if illegalEscape == nil {
+					tok := l.newToken(token.ILLEGAL_ESCAPE, "\\"+string(l.Ch), escapeLine, escapeColumn)
+					illegalEscape = &tok
+				}
 			}
 		} else {
 			if l.Ch == '"' {
 				break
 			}
 
-			str.WriteByte(l.Ch)
+			if l.Ch == '$' && l.peekChar() == '{' {
+				l.readChar()
+				l.interpolations = append(l.interpolations, 0)
+
+				if illegalEscape != nil {
+					return *illegalEscape
+				}
+
+				if head {
+					return l.newToken(token.STRING_HEAD, str.String(), startLine, startColumn)
+				}
+				return l.newToken(token.STRING_MIDDLE, str.String(), startLine, startColumn)
+			}
+
+			str.WriteRune(l.Ch)
+		}
+	}
+
+	if illegalEscape != nil {
+		return *illegalEscape
+	}
+
+	if head {
+		return l.newToken(token.STRING, str.String(), startLine, startColumn)
+	}
+	return l.newToken(token.STRING_TAIL, str.String(), startLine, startColumn)
+}
+
+// readRawString reads a backtick quoted string, its content is kept verbatim.
+func (l *Lexer) readRawString() token.Token {
+	startPosition := l.Position
+	startLine := l.line
+	startColumn := l.column
+
+	for {
+		l.readChar()
+		if l.atEOF() {
+			return l.newToken(token.UNTERMINATED_STRING, l.Input[startPosition:], startLine, startColumn)
+		}
+
+		if l.Ch == '`' {
+			break
+		}
+	}
+
+	return l.newToken(token.STRING, l.Input[startPosition+1:l.Position], startLine, startColumn)
+}
+
+func (l *Lexer) isTripleQuote() bool {
+	return strings.HasPrefix(l.Input[l.Position:], `"""`)
+}
+
+// readTripleQuotedString reads a `"""` quoted multi-line string. The common
+// indentation of its lines is removed, as well as the first and last line if
+// they are blank, before escape sequences are processed.
+func (l *Lexer) readTripleQuotedString() token.Token {
+	startPosition := l.Position
+	startLine := l.line
+	startColumn := l.column
+	var illegalEscape *token.Token
+
+	// Skip the first two quotes, the loop skips the third one
+	l.readChar()
+	l.readChar()
+	contentPosition := l.ReadPosition
+
+	for {
+		l.readChar()
+		if l.atEOF() {
+			return l.newToken(token.UNTERMINATED_STRING, l.Input[startPosition:], startLine, startColumn)
+		}
+
+		if l.Ch == '\\' {
+			_, width, ok := decodeEscape(l.Input[l.ReadPosition:])
+			if !ok {
+				if illegalEscape == nil {
+					tok := l.newToken(token.ILLEGAL_ESCAPE, "\\"+string(l.peekChar()), l.line, l.column)
+					illegalEscape = &tok
+				}
+				width = 1
+			}
+
+			for i := 0; i < width; i++ {
+				l.readChar()
+			}
+			continue
 		}
+
+		if l.isTripleQuote() {
+			break
+		}
+	}
+
+	content := l.Input[contentPosition:l.Position]
+
+	// Stop at the last quote
+	l.readChar()
+	l.readChar()
+
+	if illegalEscape != nil {
+		return *illegalEscape
 	}
+
+	return l.newToken(token.STRING, unescape(dedent(content)), startLine, startColumn)
+}
+
+func dedent(content string) string {
+	lines := strings.Split(content, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	isBlank := func(line string) bool {
+		return strings.TrimLeft(line, " \t") == ""
+	}
+
+	if len(lines) > 1 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+
+	if len(lines) > 1 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	// The whitespace all lines start with, tabs and spaces are not
+	// interchangeable
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+
+		leading := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = leading, false
+			continue
+		}
+
+		i := 0
+		for i < len(indent) && i < len(leading) && indent[i] == leading[i] {
+			i++
+		}
+		indent = indent[:i]
+	}
+
+	for i, line := range lines {
+		if isBlank(line) {
+			lines[i] = ""
+		} else {
+			lines[i] = strings.TrimPrefix(line, indent)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// decodeEscape decodes the escape sequence at the start of s, which follows a
+// backslash. It returns the decoded rune and the number of bytes consumed.
+func decodeEscape(s string) (rune, int, bool) {
+	if len(s) == 0 {
+		return 0, 0, false
+	}
+
+	switch s[0] {
+	case 'n':
+		return '\n', 1, true
+	case 't':
+		return '\t', 1, true
+	case 'r':
+		return '\r', 1, true
+	case '"', '\\', '$':
+		return rune(s[0]), 1, true
+	case 'u':
+		end := strings.IndexByte(s, '}')
+		if len(s) < 2 || s[1] != '{' || end < 3 || end > 8 {
+			return 0, 0, false
+		}
+
+		code, err := strconv.ParseUint(s[2:end], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return 0, 0, false
+		}
+
+		return rune(code), end + 1, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// unescape processes the escape sequences of s, which are already validated.
+func unescape(s string) string {
+	var str bytes.Buffer
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			str.WriteByte(s[i])
+			continue
+		}
+
+		ch, width, _ := decodeEscape(s[i+1:])
+		str.WriteRune(ch)
+		i += width
+	}
+
 	return str.String()
 }
 
+// readUnicodeEscape reads the `{...}` part of a `\u{...}` escape, with l.Ch at
+// `u`. Nothing is consumed when the escape is malformed.
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	ch, width, ok := decodeEscape(l.Input[l.Position:])
+	if !ok {
+		return 0, false
+	}
+
+	for i := 1; i < width; i++ {
+		l.readChar()
+	}
+
+	return ch, true
+}
+
 func (l *Lexer) skipWhitespace() {
-	for l.Ch == ' ' || l.Ch == '\t' || l.Ch == '\n' {
+	for l.Ch == ' ' || l.Ch == '\t' || l.Ch == '\n' || l.Ch == '\r' {
 		l.readChar()
 	}
 }
@@ -104,11 +380,11 @@ func (l *Lexer) newToken(tokenType token.TokenType, literal string, startLine in
 	}
 }
 
-func isLetter(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
@@ -124,6 +400,9 @@ func (l *Lexer) NextToken() token.Token {
 		if nextCh == '=' {
 			tok = l.newToken(token.EQ, token.EQ, l.line, startColumn)
 			l.readChar()
+		} else if nextCh == '>' {
+			tok = l.newToken(token.FAT_ARROW, token.FAT_ARROW, l.line, startColumn)
+			l.readChar()
 		} else {
 			tok = l.newToken(token.ASSIGN, string(l.Ch), l.line, startColumn)
 		}
@@ -138,8 +417,20 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		tok = l.newToken(token.PLUS, string(l.Ch), l.line, l.column)
 	case '{':
+		if depth := len(l.interpolations); depth > 0 {
+			l.interpolations[depth-1]++
+		}
 		tok = l.newToken(token.LBRACE, string(l.Ch), l.line, l.column)
 	case '}':
+		if depth := len(l.interpolations); depth > 0 {
+			if l.interpolations[depth-1] == 0 {
+				// End of an interpolated expression, continue with the string
+				l.interpolations = l.interpolations[:depth-1]
+				tok = l.readString()
+				break
+			}
+			l.interpolations[depth-1]--
+		}
 		tok = l.newToken(token.RBRACE, string(l.Ch), l.line, l.column)
 	case '[':
 		tok = l.newToken(token.LBRACKET, string(l.Ch), l.line, l.column)
@@ -148,7 +439,13 @@ func (l *Lexer) NextToken() token.Token {
 	case ':':
 		tok = l.newToken(token.COLON, string(l.Ch), l.line, l.column)
 	case '-':
-		tok = l.newToken(token.MINUS, string(l.Ch), l.line, l.column)
+		startColumn := l.column
+		if l.peekChar() == '>' {
+			tok = l.newToken(token.ARROW, token.ARROW, l.line, startColumn)
+			l.readChar()
+		} else {
+			tok = l.newToken(token.MINUS, string(l.Ch), l.line, startColumn)
+		}
 	case '!':
 		startColumn := l.column
 		nextCh := l.peekChar()
@@ -175,6 +472,9 @@ func (l *Lexer) NextToken() token.Token {
 		if nextCh == '=' {
 			tok = l.newToken(token.LTE, token.LTE, l.line, startColumn)
 			l.readChar()
+		} else if nextCh == '<' {
+			tok = l.newToken(token.LSHIFT, token.LSHIFT, l.line, startColumn)
+			l.readChar()
 		} else {
 			tok = l.newToken(token.LT, string(l.Ch), l.line, startColumn)
 		}
@@ -184,6 +484,9 @@ func (l *Lexer) NextToken() token.Token {
 		if nextCh == '=' {
 			tok = l.newToken(token.GTE, token.GTE, l.line, startColumn)
 			l.readChar()
+		} else if nextCh == '>' {
+			tok = l.newToken(token.RSHIFT, token.RSHIFT, l.line, startColumn)
+			l.readChar()
 		} else {
 			tok = l.newToken(token.GT, string(l.Ch), l.line, startColumn)
 		}
@@ -191,11 +494,41 @@ func (l *Lexer) NextToken() token.Token {
 		tok = l.newToken(token.VERTICAL, string(l.Ch), l.line, l.column)
 	case '%':
 		tok = l.newToken(token.PERCENT, string(l.Ch), l.line, l.column)
-	case '"':
-		startLine := l.line
+	case '.':
 		startColumn := l.column
-		tok = l.newToken(token.STRING, l.readString(), startLine, startColumn)
+		if l.peekChar() == '.' {
+			l.readChar()
+			if l.peekChar() == '=' {
+				tok = l.newToken(token.DOTDOT_EQ, token.DOTDOT_EQ, l.line, startColumn)
+				l.readChar()
+			} else if l.peekChar() == '.' {
+				tok = l.newToken(token.ELLIPSIS, token.ELLIPSIS, l.line, startColumn)
+				l.readChar()
+			} else {
+				tok = l.newToken(token.DOTDOT, token.DOTDOT, l.line, startColumn)
+			}
+		} else {
+			tok = l.newToken(token.DOT, string(l.Ch), l.line, startColumn)
+		}
+	case '&':
+		tok = l.newToken(token.AMPERSAND, string(l.Ch), l.line, l.column)
+	case '^':
+		tok = l.newToken(token.CARET, string(l.Ch), l.line, l.column)
+	case '~':
+		tok = l.newToken(token.TILDE, string(l.Ch), l.line, l.column)
+	case '"':
+		if l.isTripleQuote() {
+			tok = l.readTripleQuotedString()
+		} else {
+			tok = l.readString()
+		}
+	case '`':
+		tok = l.readRawString()
 	case 0:
+		if !l.atEOF() {
+			tok = l.newToken(token.ILLEGAL, string(l.Ch), l.line, l.column)
+			break
+		}
 		tok = l.newToken(token.EOF, "", l.line, l.column)
 	default:
 		startLine := l.line
@@ -212,3 +212,341 @@ func TestTokenPosition(t *testing.T) {
 		}
 	}
 }
+
+func TestUnicode(t *testing.T) {
+	input := `名字 = "中文\u{1F600}\u{4e2d}";
+	变量_1 + 名字 é
+	"\u{zz}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.IDENTIFIER, "名字", 1, 1},
+		{token.ASSIGN, "=", 1, 4},
+		{token.STRING, "中文😀中", 1, 6},
+		{token.SEMICOLON, ";", 1, 27},
+		{token.IDENTIFIER, "变量_1", 2, 2},
+		{token.PLUS, "+", 2, 7},
+		{token.IDENTIFIER, "名字", 2, 9},
+		{token.IDENTIFIER, "é", 2, 12},
+		{token.ILLEGAL_ESCAPE, `\u`, 3, 3},
+		{token.EOF, "", 3, 9},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expectd=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d", i, tt.expectedLine, tok.Line)
+		}
+
+		if tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, tok.Column)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedTokens []token.Token
+	}{
+		{
+			"x = \"abc",
+			[]token.Token{
+				{Type: token.IDENTIFIER, Literal: "x", Line: 1, Column: 1},
+				{Type: token.ASSIGN, Literal: "=", Line: 1, Column: 3},
+				{Type: token.UNTERMINATED_STRING, Literal: "\"abc", Line: 1, Column: 5},
+				{Type: token.EOF, Literal: "", Line: 1, Column: 8},
+			},
+		},
+		{
+			"\"abc\\",
+			[]token.Token{
+				{Type: token.UNTERMINATED_STRING, Literal: "\"abc\\", Line: 1, Column: 1},
+				{Type: token.EOF, Literal: "", Line: 1, Column: 5},
+			},
+		},
+		{
+			"\"a\\qb\\z\" 1",
+			[]token.Token{
+				{Type: token.ILLEGAL_ESCAPE, Literal: "\\q", Line: 1, Column: 3},
+				{Type: token.INT, Literal: "1", Line: 1, Column: 10},
+			},
+		},
+		{
+			"\"\\\\ \\r\"",
+			[]token.Token{
+				{Type: token.STRING, Literal: "\\ \r", Line: 1, Column: 1},
+			},
+		},
+		{
+			"x\r\n@ 1\r\n",
+			[]token.Token{
+				{Type: token.IDENTIFIER, Literal: "x", Line: 1, Column: 1},
+				{Type: token.ILLEGAL, Literal: "@", Line: 2, Column: 1},
+				{Type: token.INT, Literal: "1", Line: 2, Column: 3},
+				{Type: token.EOF, Literal: "", Line: 3, Column: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+		for i, expected := range tt.expectedTokens {
+			tok := l.NextToken()
+			if tok != expected {
+				t.Fatalf("input %q, tokens[%d] wrong, expected=%+v, got=%+v", tt.input, i, expected, tok)
+			}
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"a ${x + 1} b ${ {"k": "${y}"}["k"] } c\${z}" "${}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, "a "},
+		{token.IDENTIFIER, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.STRING_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.STRING_HEAD, ""},
+		{token.IDENTIFIER, "y"},
+		{token.STRING_TAIL, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.STRING_TAIL, " c${z}"},
+		{token.STRING_HEAD, ""},
+		{token.STRING_TAIL, ""},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expectd=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestRawAndMultiLineStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"`C:\\path\\n ${x} \"q\"`", token.STRING, `C:\path\n ${x} "q"`},
+		{"`line 1\nline 2`", token.STRING, "line 1\nline 2"},
+		{"`abc", token.UNTERMINATED_STRING, "`abc"},
+		{"\"\"\"\n    SELECT *\n      FROM t\n\n    WHERE a = \\\"b\\\"\\t\n    \"\"\"", token.STRING, "SELECT *\n  FROM t\n\nWHERE a = \"b\"\t"},
+		{"\"\"\"one line\"\"\"", token.STRING, "one line"},
+		{"\"\"\"\r\n\ta\r\n\t\tb\r\n\t\"\"\"", token.STRING, "a\n\tb"},
+		{"\"\"\"a \" b \"\" c\"\"\"", token.STRING, "a \" b \"\" c"},
+		{"\"\"\"\n\t  a\n\t    b\n  \tc\n\t  \"\"\"", token.STRING, "\t  a\n\t    b\n  \tc"},
+		{"\"\"\"\n\t  a\n\t\tb\n\t\"\"\"", token.STRING, "  a\n\tb"},
+		{"\"\"\"\n  abc\n", token.UNTERMINATED_STRING, "\"\"\"\n  abc\n"},
+		{"\"\"\"a\\qb\"\"\"", token.ILLEGAL_ESCAPE, "\\q"},
+		{"\"\"", token.STRING, ""},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("input %q - tokentype wrong. expectd=%q, got=%q", tt.input, tt.expectedType, tok.Type)
+			continue
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("input %q - literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("input %q - expected EOF after string, got=%q", tt.input, next.Type)
+		}
+	}
+}
+
+func TestBitwiseTokens(t *testing.T) {
+	input := `0xFF & ~x ^ 0b10 bor 1_000 << 2 >> 0o7 <= >=`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0xFF"},
+		{token.AMPERSAND, "&"},
+		{token.TILDE, "~"},
+		{token.IDENTIFIER, "x"},
+		{token.CARET, "^"},
+		{token.INT, "0b10"},
+		{token.BOR, "bor"},
+		{token.INT, "1_000"},
+		{token.LSHIFT, "<<"},
+		{token.INT, "2"},
+		{token.RSHIFT, ">>"},
+		{token.INT, "0o7"},
+		{token.LTE, "<="},
+		{token.GTE, ">="},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expectd=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIterationTokens(t *testing.T) {
+	input := `for x in [...xs] { yield x } [a, ..rest] 1..=2`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FOR, "for"},
+		{token.IDENTIFIER, "x"},
+		{token.IN, "in"},
+		{token.LBRACKET, "["},
+		{token.ELLIPSIS, "..."},
+		{token.IDENTIFIER, "xs"},
+		{token.RBRACKET, "]"},
+		{token.LBRACE, "{"},
+		{token.YIELD, "yield"},
+		{token.IDENTIFIER, "x"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.IDENTIFIER, "a"},
+		{token.COMMA, ","},
+		{token.DOTDOT, ".."},
+		{token.IDENTIFIER, "rest"},
+		{token.RBRACKET, "]"},
+		{token.INT, "1"},
+		{token.DOTDOT_EQ, "..="},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expectd=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStructTokens(t *testing.T) {
+	input := `struct Point { x, y } p.x..p.y`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRUCT, "struct"},
+		{token.IDENTIFIER, "Point"},
+		{token.LBRACE, "{"},
+		{token.IDENTIFIER, "x"},
+		{token.COMMA, ","},
+		{token.IDENTIFIER, "y"},
+		{token.RBRACE, "}"},
+		{token.IDENTIFIER, "p"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "x"},
+		{token.DOTDOT, ".."},
+		{token.IDENTIFIER, "p"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "y"},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expectd=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestArrowToken(t *testing.T) {
+	input := `|a: int| -> int { a - 1 }`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.VERTICAL, "|"},
+		{token.IDENTIFIER, "a"},
+		{token.COLON, ":"},
+		{token.IDENTIFIER, "int"},
+		{token.VERTICAL, "|"},
+		{token.ARROW, "->"},
+		{token.IDENTIFIER, "int"},
+		{token.LBRACE, "{"},
+		{token.IDENTIFIER, "a"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expectd=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
@@ -4,12 +4,43 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/vita-dounai/Firework/checker"
+	"github.com/vita-dounai/Firework/dap"
+	"github.com/vita-dounai/Firework/evaluator"
+	"github.com/vita-dounai/Firework/optimizer"
 	"github.com/vita-dounai/Firework/repl"
 )
 
 func main() {
+	args := os.Args[1:]
+
+	level := optimizer.FULL
+	if len(args) > 0 && strings.HasPrefix(args[0], "-O") {
+		level = optimizationLevel(args[0])
+		args = args[1:]
+	}
+
+	if len(args) > 1 && args[0] == "check" {
+		check(args[1])
+		return
+	}
+
+	if len(args) > 0 && args[0] == "dap" {
+		// Programs are debugged unoptimized, as written
+		serveDebugAdapter()
+		return
+	}
+
+	if len(args) > 0 {
+		evaluator.OptimizationLevel = level
+		run(args[0])
+		return
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -31,3 +62,59 @@ func main() {
 	fmt.Println(greeting)
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func absolutePath(path string) string {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return absolute
+}
+
+// optimizationLevel parses a flag such as -O2.
+func optimizationLevel(flag string) optimizer.Level {
+	level, err := strconv.Atoi(strings.TrimPrefix(flag, "-O"))
+	if err != nil || level < int(optimizer.NONE) || level > int(optimizer.FULL) {
+		fmt.Fprintf(os.Stderr, "Unknown optimization level: %s, expected -O0 to -O%d\n", flag, optimizer.FULL)
+		os.Exit(1)
+	}
+	return optimizer.Level(level)
+}
+
+func run(path string) {
+	absolute := absolutePath(path)
+
+	if _, evalErr := evaluator.LoadModule(absolute); evalErr != nil {
+		fmt.Fprintln(os.Stderr, evalErr.Inspect())
+		os.Exit(1)
+	}
+}
+
+// check reports the type errors of the file at path without running it.
+func check(path string) {
+	program, err := evaluator.ExpandModule(absolutePath(path))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		os.Exit(1)
+	}
+
+	c := checker.NewChecker()
+	c.Check(program)
+
+	for _, message := range c.Errors() {
+		fmt.Fprintln(os.Stderr, message)
+	}
+
+	if len(c.Errors()) != 0 {
+		os.Exit(1)
+	}
+}
+
+// serveDebugAdapter speaks the Debug Adapter Protocol over stdin and stdout.
+func serveDebugAdapter() {
+	if err := dap.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
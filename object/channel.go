@@ -0,0 +1,261 @@
+package object
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrDeadlock      = errors.New("Deadlock: all tasks are blocked")
+	ErrClosedChannel = errors.New("Send on closed channel")
+	ErrDoubleClose   = errors.New("Close of closed channel")
+)
+
+// scheduler tracks the tasks running Firework code. All channel state is
+// guarded by its lock, so a task can check whether it is the last one able
+// to make progress before it blocks.
+var scheduler = struct {
+	sync.Mutex
+	cond *sync.Cond
+
+	tasks   int // The main task is always counted
+	blocked int
+	// Bumped on every detected deadlock to fail the blocked tasks
+	deadlocks int
+}{tasks: 1}
+
+func init() {
+	scheduler.cond = sync.NewCond(&scheduler.Mutex)
+}
+
+// broadcast wakes every blocked task, the caller must hold the lock.
+func broadcast() {
+	scheduler.blocked = 0
+	scheduler.cond.Broadcast()
+}
+
+// wait blocks until some channel changes, the caller must hold the lock.
+func wait() error {
+	scheduler.blocked++
+	if scheduler.blocked >= scheduler.tasks {
+		scheduler.deadlocks++
+		broadcast()
+		return ErrDeadlock
+	}
+
+	deadlocks := scheduler.deadlocks
+	scheduler.cond.Wait()
+
+	if scheduler.deadlocks != deadlocks {
+		return ErrDeadlock
+	}
+
+	return nil
+}
+
+// Spawn runs task on a new goroutine, accounted as a separate task.
+func Spawn(task func()) {
+	scheduler.Lock()
+	scheduler.tasks++
+	scheduler.Unlock()
+
+	go func() {
+		defer func() {
+			scheduler.Lock()
+			scheduler.tasks--
+			if scheduler.blocked > 0 && scheduler.blocked >= scheduler.tasks {
+				scheduler.deadlocks++
+				broadcast()
+			}
+			scheduler.Unlock()
+		}()
+
+		task()
+	}()
+}
+
+// selection is one blocking send, receive or select. Its values are offered
+// to receivers until one of them is taken.
+type selection struct {
+	done   bool
+	chosen int
+}
+
+type pendingSend struct {
+	value     Object
+	selection *selection
+	index     int
+}
+
+type Channel struct {
+	capacity int
+	buffer   []Object
+	senders  []*pendingSend
+	closed   bool
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{capacity: capacity}
+}
+
+func (c *Channel) Inspect() string  { return "<channel>" }
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+
+// nextSender removes the first sender still waiting, other than self, and
+// drops the senders whose selection is already done.
+func (c *Channel) nextSender(self *selection) *pendingSend {
+	var next *pendingSend
+	waiting := c.senders[:0]
+
+	for _, sender := range c.senders {
+		if sender.selection.done {
+			continue
+		}
+
+		if next == nil && sender.selection != self {
+			next = sender
+			continue
+		}
+
+		waiting = append(waiting, sender)
+	}
+
+	c.senders = waiting
+	return next
+}
+
+func (c *Channel) tryRecv(self *selection) (value Object, ok bool, ready bool) {
+	if len(c.buffer) > 0 {
+		value = c.buffer[0]
+		c.buffer = c.buffer[1:]
+
+		if sender := c.nextSender(self); sender != nil {
+			c.buffer = append(c.buffer, sender.value)
+			sender.selection.done, sender.selection.chosen = true, sender.index
+		}
+
+		return value, true, true
+	}
+
+	if sender := c.nextSender(self); sender != nil {
+		sender.selection.done, sender.selection.chosen = true, sender.index
+		return sender.value, true, true
+	}
+
+	if c.closed {
+		return nil, false, true
+	}
+
+	return nil, false, false
+}
+
+func (c *Channel) trySend(value Object) (ready bool, err error) {
+	if c.closed {
+		return true, ErrClosedChannel
+	}
+
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, value)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (c *Channel) Send(value Object) error {
+	_, _, _, err := Select([]SelectCase{{Channel: c, Send: true, Value: value}}, false)
+	return err
+}
+
+// Recv receives a value, ok is false once the channel is closed and drained.
+func (c *Channel) Recv() (value Object, ok bool, err error) {
+	_, value, ok, err = Select([]SelectCase{{Channel: c}}, false)
+	return value, ok, err
+}
+
+func (c *Channel) Close() error {
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	if c.closed {
+		return ErrDoubleClose
+	}
+
+	c.closed = true
+	broadcast()
+	return nil
+}
+
+// Iter receives from the channel until it is closed.
+func (c *Channel) Iter() *Iterator {
+	return NewIterator("iterator", func() (Object, bool) {
+		value, ok, err := c.Recv()
+		if err != nil {
+			return &Error{Message: err.Error()}, true
+		}
+
+		return value, ok
+	})
+}
+
+type SelectCase struct {
+	Channel *Channel
+	Send    bool
+	Value   Object // Sent value
+}
+
+// Select blocks until one of the cases can proceed and returns its index,
+// or -1 right away if none can and there is a default case. For a receive,
+// value and ok are as returned by Recv.
+func Select(cases []SelectCase, hasDefault bool) (chosen int, value Object, ok bool, err error) {
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	self := &selection{}
+	try := func() (int, Object, bool, error, bool) {
+		for i, c := range cases {
+			if c.Send {
+				if ready, err := c.Channel.trySend(c.Value); ready {
+					return i, nil, false, err, true
+				}
+			} else if value, ok, ready := c.Channel.tryRecv(self); ready {
+				return i, value, ok, nil, true
+			}
+		}
+		return 0, nil, false, nil, false
+	}
+
+	if chosen, value, ok, err, ready := try(); ready {
+		broadcast()
+		return chosen, value, ok, err
+	}
+
+	if hasDefault {
+		return -1, nil, false, nil
+	}
+
+	// Offer the values to be sent until a receiver takes one of them
+	for i, c := range cases {
+		if c.Send {
+			c.Channel.senders = append(c.Channel.senders, &pendingSend{value: c.Value, selection: self, index: i})
+		}
+	}
+	broadcast()
+
+	for {
+		if err := wait(); err != nil {
+			self.done = true
+			return 0, nil, false, err
+		}
+
+		if self.done {
+			return self.chosen, nil, false, nil
+		}
+
+		if chosen, value, ok, err, ready := try(); ready {
+			self.done = true
+			broadcast()
+			return chosen, value, ok, err
+		}
+	}
+}
@@ -1,8 +1,21 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
+// Environment may be shared by spawned tasks, so its store is guarded. The
+// outermost environment keeps its variables in a map, the frames extending it
+// keep theirs in slots which the resolver assigns to names.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu     sync.RWMutex
+	store  map[string]Object // Nil for frames
+	names  []string
+	values []Object
+	// Whether each slot is assigned, nil being a legal value
+	defined []bool
+	outer   *Environment
 }
 
 func NewEnvironment() *Environment {
@@ -10,14 +23,52 @@ func NewEnvironment() *Environment {
 	return &Environment{store: env}
 }
 
+// ExtendEnvironment returns a frame without slots, they are added as names
+// are defined.
 func ExtendEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	return &Environment{outer: outer}
+}
+
+// NewFrame returns a frame with a slot for each of names, laid out by the
+// resolver.
+func NewFrame(outer *Environment, names []string) *Environment {
+	return &Environment{
+		// Never append to the layout, it is shared by all frames of a block
+		names:   names[:len(names):len(names)],
+		values:  make([]Object, len(names)),
+		defined: make([]bool, len(names)),
+		outer:   outer,
+	}
+}
+
+// index returns the slot of name in a frame, or -1.
+func (e *Environment) index(name string) int {
+	for i, n := range e.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup must be called with the lock held.
+func (e *Environment) lookup(name string) (Object, bool) {
+	if e.store != nil {
+		obj, ok := e.store[name]
+		return obj, ok
+	}
+
+	if i := e.index(name); i >= 0 && e.defined[i] {
+		return e.values[i], true
+	}
+	return nil, false
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
+	e.mu.RLock()
+	obj, ok := e.lookup(name)
+	e.mu.RUnlock()
+
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -25,8 +76,14 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) setIfExist(name string, value Object) bool {
-	if _, ok := e.store[name]; ok {
-		e.store[name] = value
+	e.mu.Lock()
+	_, ok := e.lookup(name)
+	if ok {
+		e.define(name, value)
+	}
+	e.mu.Unlock()
+
+	if ok {
 		return true
 	}
 
@@ -39,7 +96,111 @@ func (e *Environment) setIfExist(name string, value Object) bool {
 
 func (e *Environment) Set(name string, value Object) Object {
 	if !e.setIfExist(name, value) {
+		e.Define(name, value)
+	}
+	return value
+}
+
+// Define binds name in this environment only, shadowing any outer binding.
+func (e *Environment) Define(name string, value Object) Object {
+	e.mu.Lock()
+	e.define(name, value)
+	e.mu.Unlock()
+	return value
+}
+
+func (e *Environment) define(name string, value Object) {
+	if e.store != nil {
 		e.store[name] = value
+		return
+	}
+
+	if i := e.index(name); i >= 0 {
+		e.values[i] = value
+		e.defined[i] = true
+		return
 	}
+
+	e.names = append(e.names, name)
+	e.values = append(e.values, value)
+	e.defined = append(e.defined, true)
+}
+
+// frame returns the environment depth frames out of e.
+func (e *Environment) frame(depth int) *Environment {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	return e
+}
+
+// GetAt returns the value in slot index of the frame depth frames out of e.
+func (e *Environment) GetAt(depth, index int) (Object, bool) {
+	f := e.frame(depth)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if index < len(f.values) && f.defined[index] {
+		return f.values[index], true
+	}
+	return nil, false
+}
+
+// SetAt assigns slot index of the frame depth frames out of e to name.
+func (e *Environment) SetAt(depth, index int, name string, value Object) Object {
+	f := e.frame(depth)
+
+	f.mu.Lock()
+	if index >= len(f.values) {
+		// The frame was not laid out by the resolver
+		for len(f.values) <= index {
+			f.names = append(f.names, "")
+			f.values = append(f.values, nil)
+			f.defined = append(f.defined, false)
+		}
+		f.names[index] = name
+	}
+	f.values[index] = value
+	f.defined[index] = true
+	f.mu.Unlock()
+
 	return value
 }
+
+// Binding is a variable defined in an environment.
+type Binding struct {
+	Name  string
+	Value Object
+}
+
+// Bindings returns the variables holding a value in e itself, frames list
+// them in the order of their slots and the outermost environment sorted by
+// name.
+func (e *Environment) Bindings() []Binding {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	bindings := []Binding{}
+	if e.store != nil {
+		for name, value := range e.store {
+			if value != nil {
+				bindings = append(bindings, Binding{Name: name, Value: value})
+			}
+		}
+		sort.Slice(bindings, func(i, j int) bool { return bindings[i].Name < bindings[j].Name })
+		return bindings
+	}
+
+	for i, name := range e.names {
+		if e.values[i] != nil {
+			bindings = append(bindings, Binding{Name: name, Value: e.values[i]})
+		}
+	}
+	return bindings
+}
+
+// Outer returns the environment e extends, nil for the outermost one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
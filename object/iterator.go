@@ -0,0 +1,107 @@
+package object
+
+// Iterable is implemented by objects whose elements can be walked by an
+// Iterator, such as for loops, spreads and collection builtins do.
+type Iterable interface {
+	Object
+	Iter() *Iterator
+}
+
+// Iterator produces the elements of a sequence lazily. Errors raised while
+// producing an element are returned as the element itself.
+type Iterator struct {
+	Name string // Shown by Inspect
+
+	next      func() (Object, bool)
+	peeked    Object
+	hasPeeked bool
+	exhausted bool
+}
+
+func NewIterator(name string, next func() (Object, bool)) *Iterator {
+	return &Iterator{Name: name, next: next}
+}
+
+func (it *Iterator) Inspect() string  { return "<" + it.Name + ">" }
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Iter() *Iterator  { return it }
+
+// Next returns the next element, the boolean is false once the iterator is
+// exhausted.
+func (it *Iterator) Next() (Object, bool) {
+	if it.hasPeeked {
+		it.hasPeeked = false
+		return it.peeked, true
+	}
+
+	if it.exhausted {
+		return nil, false
+	}
+
+	value, ok := it.next()
+	if !ok {
+		it.exhausted = true
+		it.next = nil
+	}
+
+	return value, ok
+}
+
+// Done reports whether the iterator is exhausted, which may require producing
+// the next element ahead of time.
+func (it *Iterator) Done() bool {
+	if it.hasPeeked {
+		return false
+	}
+
+	value, ok := it.Next()
+	if !ok {
+		return true
+	}
+
+	it.peeked, it.hasPeeked = value, true
+	return false
+}
+
+func elementsIterator(elements []Object) *Iterator {
+	index := 0
+	return NewIterator("iterator", func() (Object, bool) {
+		if index >= len(elements) {
+			return nil, false
+		}
+
+		index++
+		return elements[index-1], true
+	})
+}
+
+func (a *Array) Iter() *Iterator { return elementsIterator(a.Elements) }
+func (t *Tuple) Iter() *Iterator { return elementsIterator(t.Elements) }
+
+// Iter walks the characters of the string.
+func (s *String) Iter() *Iterator {
+	runes := []rune(s.Value)
+	index := 0
+	return NewIterator("iterator", func() (Object, bool) {
+		if index >= len(runes) {
+			return nil, false
+		}
+
+		index++
+		return &String{Value: string(runes[index-1])}, true
+	})
+}
+
+// Iter walks the keys of the map in insertion order.
+func (m *Map) Iter() *Iterator {
+	pairs := m.OrderedPairs()
+	index := 0
+	return NewIterator("iterator", func() (Object, bool) {
+		if index >= len(pairs) {
+			return nil, false
+		}
+
+		index++
+		return pairs[index-1].Key, true
+	})
+}
@@ -0,0 +1,172 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type MapPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hasher func(key Hashable) HashKey
+
+func DefaultHasher(key Hashable) HashKey {
+	return key.Hash()
+}
+
+// Map stores its pairs in buckets indexed by HashKey, keys in the same bucket
+// are told apart with Equals. Pairs are kept in insertion order, so that
+// Inspect and iteration over a map are deterministic.
+//
+// Maps may be shared by spawned tasks, so their pairs are guarded. Keys are
+// hashed and compared without holding the lock, as it may call methods of
+// struct keys.
+type Map struct {
+	mu      sync.RWMutex
+	buckets map[HashKey][]*MapPair
+	order   []*MapPair
+	hasher  Hasher
+}
+
+func NewMap() *Map {
+	return NewMapWithHasher(DefaultHasher)
+}
+
+func NewMapWithHasher(hasher Hasher) *Map {
+	return &Map{
+		buckets: make(map[HashKey][]*MapPair),
+		order:   []*MapPair{},
+		hasher:  hasher,
+	}
+}
+
+// bucket returns the pairs whose keys have hashKey, pairs are only ever
+// appended to it.
+func (m *Map) bucket(hashKey HashKey) []*MapPair {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.buckets[hashKey]
+}
+
+func findPair(pairs []*MapPair, key Hashable) *MapPair {
+	for _, pair := range pairs {
+		if Equals(pair.Key, key) {
+			return pair
+		}
+	}
+
+	return nil
+}
+
+// Set inserts or updates a pair, an updated key keeps its original position.
+func (m *Map) Set(key Hashable, value Object) {
+	hashKey := m.hasher(key)
+
+	checked := 0
+	for {
+		bucket := m.bucket(hashKey)
+		if pair := findPair(bucket[checked:], key); pair != nil {
+			m.mu.Lock()
+			pair.Value = value
+			m.mu.Unlock()
+			return
+		}
+
+		m.mu.Lock()
+		if len(m.buckets[hashKey]) == len(bucket) {
+			pair := &MapPair{Key: key, Value: value}
+			m.buckets[hashKey] = append(m.buckets[hashKey], pair)
+			m.order = append(m.order, pair)
+			m.mu.Unlock()
+			return
+		}
+		m.mu.Unlock()
+
+		// Another task added pairs meanwhile, only those are compared again
+		checked = len(bucket)
+	}
+}
+
+func (m *Map) Get(key Hashable) (Object, bool) {
+	pair := findPair(m.bucket(m.hasher(key)), key)
+	if pair == nil {
+		return nil, false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return pair.Value, true
+}
+
+func (m *Map) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.order)
+}
+
+// OrderedPairs returns all pairs of the map in insertion order.
+func (m *Map) OrderedPairs() []MapPair {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pairs := make([]MapPair, 0, len(m.order))
+	for _, pair := range m.order {
+		pairs = append(pairs, *pair)
+	}
+	return pairs
+}
+
+func (m *Map) Inspect() string {
+	str, _ := m.inspect()
+	return str
+}
+func (m *Map) inspect() (string, Object) {
+	var out bytes.Buffer
+
+	objects := []Object{}
+	for _, pair := range m.OrderedPairs() {
+		objects = append(objects, pair.Key, pair.Value)
+	}
+	strs, err := inspectAll(objects)
+
+	pairs := []string{}
+	for i := 0; i < len(strs); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", strs[i], strs[i+1]))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String(), err
+}
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Equals(other Object) bool {
+	equal, _ := m.equals(other)
+	return equal
+}
+func (m *Map) equals(other Object) (bool, Object) {
+	otherMap, ok := other.(*Map)
+	if !ok || m.Len() != otherMap.Len() {
+		return false, nil
+	}
+
+	for _, pair := range m.OrderedPairs() {
+		value, ok := otherMap.Get(pair.Key.(Hashable))
+		if !ok {
+			return false, nil
+		}
+		if equal, err := Equal(pair.Value, value); !equal || err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package object
+
+// Module is the environment a Firework file was evaluated in.
+type Module struct {
+	Name string
+	Path string
+	Env  *Environment
+	// Names visible to importers, nil exports every top-level binding
+	Exports map[string]bool
+	// Paths of the modules whose imports loaded it, outermost first
+	Importers []string
+}
+
+func (m *Module) Inspect() string  { return "<module " + m.Name + ">" }
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+
+// Get returns the exported binding name.
+func (m *Module) Get(name string) (Object, bool) {
+	if m.Exports != nil && !m.Exports[name] {
+		return nil, false
+	}
+
+	return m.Env.Get(name)
+}
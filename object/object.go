@@ -2,8 +2,10 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math/big"
 	"strings"
 
 	"github.com/vita-dounai/Firework/ast"
@@ -21,11 +23,18 @@ const (
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
+	TUPLE_OBJ        = "TUPLE"
 	BREAK            = "BREAK"
 	CONTINUE         = "CONTINUE"
 	MAP_OBJ          = "MAP"
 	QUOTE_OBJ        = "QUOTE"
 	MACRO_OBJ        = "MARCO"
+	ITERATOR_OBJ     = "ITERATOR"
+	CHANNEL_OBJ      = "CHANNEL"
+	MODULE_OBJ       = "MODULE"
+	STRUCT_TYPE_OBJ  = "STRUCT_TYPE"
+	STRUCT_OBJ       = "STRUCT"
+	TRAIT_OBJ        = "TRAIT"
 )
 
 type Object interface {
@@ -39,9 +48,66 @@ type HashKey struct {
 }
 
 type Hashable interface {
+	Object
 	Hash() HashKey
 }
 
+// Comparable objects are compared by value rather than by identity, hash
+// collisions in Map are resolved with it as well.
+type Comparable interface {
+	Equals(other Object) bool
+}
+
+func Equals(left, right Object) bool {
+	if comparable, ok := left.(Comparable); ok {
+		return comparable.Equals(right)
+	}
+
+	return left == right
+}
+
+// fallible objects may hold structs, whose protocol methods can fail where
+// Equals and Inspect have no way to tell.
+type fallible interface {
+	equals(other Object) (bool, Object)
+	inspect() (string, Object)
+}
+
+// Equal is Equals, it also returns the error raised by an equals method.
+func Equal(left, right Object) (bool, Object) {
+	if f, ok := left.(fallible); ok {
+		return f.equals(right)
+	}
+
+	return Equals(left, right), nil
+}
+
+// Inspect is obj.Inspect, it also returns the error raised by a to_string
+// method.
+func Inspect(obj Object) (string, Object) {
+	if f, ok := obj.(fallible); ok {
+		return f.inspect()
+	}
+
+	return obj.Inspect(), nil
+}
+
+// inspectAll inspects objects in order, the first error is returned.
+func inspectAll(objects []Object) ([]string, Object) {
+	var err Object
+
+	strs := []string{}
+	for _, obj := range objects {
+		str, objErr := Inspect(obj)
+		if err == nil {
+			err = objErr
+		}
+		strs = append(strs, str)
+	}
+
+	return strs, err
+}
+
 type Integer struct {
 	Hashable
 	Value int64
@@ -52,6 +118,52 @@ func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Hash() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
+func (i *Integer) Equals(other Object) bool {
+	otherInteger, ok := other.(*Integer)
+	return ok && i.Value == otherInteger.Value
+}
+
+// BigInteger holds integers that do not fit in int64. Arithmetic switches to
+// it on overflow and results are normalized back to Integer whenever they fit,
+// so a BigInteger never holds a value representable by Integer.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+func (bi *BigInteger) Type() ObjectType { return INTEGER_OBJ }
+func (bi *BigInteger) Hash() HashKey {
+	hash := fnv.New64a()
+	hash.Write([]byte{byte(bi.Value.Sign() + 1)})
+	hash.Write(bi.Value.Bytes())
+	return HashKey{Type: bi.Type(), Value: hash.Sum64()}
+}
+func (bi *BigInteger) Equals(other Object) bool {
+	otherInteger, ok := other.(*BigInteger)
+	return ok && bi.Value.Cmp(otherInteger.Value) == 0
+}
+
+// NewInteger returns an Integer if value fits in int64, otherwise a BigInteger.
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInteger{Value: value}
+}
+
+// ToBigInt converts an Integer or BigInteger to *big.Int, it returns nil for
+// any other object.
+func ToBigInt(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInteger:
+		return new(big.Int).Set(obj.Value)
+	default:
+		return nil
+	}
+}
 
 type Boolean struct {
 	Hashable
@@ -71,11 +183,19 @@ func (b *Boolean) Hash() HashKey {
 
 	return HashKey{Type: b.Type(), Value: value}
 }
+func (b *Boolean) Equals(other Object) bool {
+	otherBoolean, ok := other.(*Boolean)
+	return ok && b.Value == otherBoolean.Value
+}
 
 type Null struct{}
 
 func (n *Null) Inspect() string  { return "null" }
 func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Equals(other Object) bool {
+	_, ok := other.(*Null)
+	return ok
+}
 
 type ReturnValue struct {
 	Value Object
@@ -86,15 +206,28 @@ func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 
 type Error struct {
 	Message string
+	// Names of the functions the error propagated through, innermost first
+	Trace []string
 }
 
-func (e *Error) Inspect() string  { return e.Message }
+func (e *Error) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Message)
+	for _, name := range e.Trace {
+		out.WriteString("\n    in fn " + name)
+	}
+
+	return out.String()
+}
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
 type Function struct {
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	Name        string // Empty for anonymous functions
+	Parameters  []*ast.Identifier
+	Body        *ast.BlockStatement
+	Env         *Environment
+	IsGenerator bool
 }
 
 func (f *Function) Inspect() string {
@@ -105,9 +238,15 @@ func (f *Function) Inspect() string {
 		parameters = append(parameters, p.String())
 	}
 
-	out.WriteString("|")
-	out.WriteString(strings.Join(parameters, ", "))
-	out.WriteString("| ")
+	if f.Name != "" {
+		out.WriteString("fn " + f.Name + "(")
+		out.WriteString(strings.Join(parameters, ", "))
+		out.WriteString(") ")
+	} else {
+		out.WriteString("|")
+		out.WriteString(strings.Join(parameters, ", "))
+		out.WriteString("| ")
+	}
 	out.WriteString(f.Body.String())
 
 	return out.String()
@@ -128,6 +267,10 @@ func (s *String) Hash() HashKey {
 	hash.Write([]byte(s.Value))
 	return HashKey{Type: s.Type(), Value: hash.Sum64()}
 }
+func (s *String) Equals(other Object) bool {
+	otherString, ok := other.(*String)
+	return ok && s.Value == otherString.Value
+}
 
 type BuiltFunction func(args ...Object) Object
 
@@ -143,52 +286,128 @@ type Array struct {
 }
 
 func (a *Array) Inspect() string {
+	str, _ := a.inspect()
+	return str
+}
+func (a *Array) inspect() (string, Object) {
 	var out bytes.Buffer
 
-	elements := []string{}
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
-	}
+	elements, err := inspectAll(a.Elements)
 
 	out.WriteString("[")
 	out.WriteString(strings.Join(elements, ", "))
 	out.WriteString("]")
 
-	return out.String()
+	return out.String(), err
 }
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
-
-type LoopControl struct {
-	ControlType ObjectType
+func (a *Array) Equals(other Object) bool {
+	equal, _ := a.equals(other)
+	return equal
+}
+func (a *Array) equals(other Object) (bool, Object) {
+	otherArray, ok := other.(*Array)
+	if !ok {
+		return false, nil
+	}
+	return elementsEqual(a.Elements, otherArray.Elements)
 }
 
-func (lc *LoopControl) Inspect() string  { return "" }
-func (lc *LoopControl) Type() ObjectType { return lc.ControlType }
+func elementsEqual(left, right []Object) (bool, Object) {
+	if len(left) != len(right) {
+		return false, nil
+	}
 
-type MapPair struct {
-	Key   Object
-	Value Object
+	for i := range left {
+		if equal, err := Equal(left[i], right[i]); !equal || err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
 }
 
-type Map struct {
-	Pairs map[HashKey]MapPair
+// Tuple is an immutable sequence, it is hashable as long as all of its
+// elements are, which makes it usable as a composite map key.
+type Tuple struct {
+	Elements []Object
 }
 
-func (m *Map) Inspect() string {
+func (t *Tuple) Inspect() string {
+	str, _ := t.inspect()
+	return str
+}
+func (t *Tuple) inspect() (string, Object) {
 	var out bytes.Buffer
 
-	pairs := []string{}
-	for _, pair := range m.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	elements, err := inspectAll(t.Elements)
+
+	out.WriteString("(")
+	out.WriteString(strings.Join(elements, ", "))
+	if len(elements) == 1 {
+		out.WriteString(",")
 	}
+	out.WriteString(")")
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+	return out.String(), err
+}
+func (t *Tuple) Type() ObjectType { return TUPLE_OBJ }
+func (t *Tuple) Hash() HashKey {
+	hash := fnv.New64a()
+	buffer := make([]byte, 8)
+
+	for _, e := range t.Elements {
+		// Elements are checked by AsHashable before a tuple is used as key
+		hashKey := e.(Hashable).Hash()
+		hash.Write([]byte(hashKey.Type))
+		binary.LittleEndian.PutUint64(buffer, hashKey.Value)
+		hash.Write(buffer)
+	}
 
-	return out.String()
+	return HashKey{Type: t.Type(), Value: hash.Sum64()}
+}
+func (t *Tuple) Equals(other Object) bool {
+	equal, _ := t.equals(other)
+	return equal
+}
+func (t *Tuple) equals(other Object) (bool, Object) {
+	otherTuple, ok := other.(*Tuple)
+	if !ok {
+		return false, nil
+	}
+	return elementsEqual(t.Elements, otherTuple.Elements)
+}
+
+// AsHashable reports whether obj can be used as a map key. Composite objects
+// like Tuple are only hashable when all of their elements are.
+func AsHashable(obj Object) (Hashable, bool) {
+	hashable, ok := obj.(Hashable)
+	if !ok {
+		return nil, false
+	}
+
+	if s, ok := obj.(*Struct); ok {
+		_, ok := s.Definition.Methods["hash"]
+		return hashable, ok
+	}
+
+	if tuple, ok := obj.(*Tuple); ok {
+		for _, e := range tuple.Elements {
+			if _, ok := AsHashable(e); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return hashable, true
 }
-func (m *Map) Type() ObjectType { return MAP_OBJ }
+
+type LoopControl struct {
+	ControlType ObjectType
+}
+
+func (lc *LoopControl) Inspect() string  { return "" }
+func (lc *LoopControl) Type() ObjectType { return lc.ControlType }
 
 type Quote struct {
 	Node ast.Node
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -32,3 +35,214 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("objects with different type have same hash keys")
 	}
 }
+
+func TestMapHashCollision(t *testing.T) {
+	collidingHasher := func(key Hashable) HashKey {
+		return HashKey{Type: key.Type(), Value: 42}
+	}
+
+	m := NewMapWithHasher(collidingHasher)
+	m.Set(&String{Value: "foo"}, &Integer{Value: 1})
+	m.Set(&String{Value: "bar"}, &Integer{Value: 2})
+	m.Set(&Integer{Value: 42}, &Integer{Value: 3})
+	m.Set(&String{Value: "foo"}, &Integer{Value: 4})
+
+	if m.Len() != 3 {
+		t.Fatalf("map has wrong num of pairs, got=%d, want=3", m.Len())
+	}
+
+	tests := []struct {
+		key      Hashable
+		expected int64
+	}{
+		{&String{Value: "foo"}, 4},
+		{&String{Value: "bar"}, 2},
+		{&Integer{Value: 42}, 3},
+	}
+
+	for _, tt := range tests {
+		value, ok := m.Get(tt.key)
+		if !ok {
+			t.Errorf("no pair for key %s", tt.key.Inspect())
+			continue
+		}
+
+		if value.(*Integer).Value != tt.expected {
+			t.Errorf("wrong value for key %s, got=%d, want=%d",
+				tt.key.Inspect(), value.(*Integer).Value, tt.expected)
+		}
+	}
+
+	if _, ok := m.Get(&String{Value: "baz"}); ok {
+		t.Errorf("unexpected pair for key \"baz\"")
+	}
+
+	expected := `{"foo": 4, "bar": 2, 42: 3}`
+	if m.Inspect() != expected {
+		t.Errorf("wrong Inspect, got=%q, want=%q", m.Inspect(), expected)
+	}
+}
+
+func TestTupleHashKey(t *testing.T) {
+	tuple1 := &Tuple{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	tuple2 := &Tuple{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	tuple3 := &Tuple{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+
+	if tuple1.Hash() != tuple2.Hash() {
+		t.Errorf("tuples with same elements have different hash keys")
+	}
+
+	if tuple1.Hash() == tuple3.Hash() {
+		t.Errorf("tuples with different elements have same hash keys")
+	}
+
+	if !Equals(tuple1, tuple2) || Equals(tuple1, tuple3) {
+		t.Errorf("tuples are not compared by elements")
+	}
+
+	unhashable := &Tuple{Elements: []Object{&Array{Elements: []Object{}}}}
+	if _, ok := AsHashable(unhashable); ok {
+		t.Errorf("tuple with an array element should not be hashable")
+	}
+}
+
+func TestIterators(t *testing.T) {
+	m := NewMap()
+	m.Set(&String{Value: "b"}, &Integer{Value: 2})
+	m.Set(&String{Value: "a"}, &Integer{Value: 1})
+
+	tests := []struct {
+		iterable Iterable
+		expected []string
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, []string{"1", "2"}},
+		{&Tuple{Elements: []Object{&Boolean{Value: true}}}, []string{"true"}},
+		{&String{Value: "hé"}, []string{`"h"`, `"é"`}},
+		{m, []string{`"b"`, `"a"`}},
+		{&Array{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		iterator := tt.iterable.Iter()
+		for i, expected := range tt.expected {
+			if iterator.Done() {
+				t.Fatalf("iterator of %s is done after %d elements", tt.iterable.Inspect(), i)
+			}
+
+			value, ok := iterator.Next()
+			if !ok || value.Inspect() != expected {
+				t.Errorf("wrong element %d of %s, expected=%s, got=%v", i, tt.iterable.Inspect(), expected, value)
+			}
+		}
+
+		if !iterator.Done() {
+			t.Errorf("iterator of %s is not done", tt.iterable.Inspect())
+		}
+
+		if _, ok := iterator.Next(); ok {
+			t.Errorf("exhausted iterator of %s returned an element", tt.iterable.Inspect())
+		}
+	}
+}
+
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("shared", &Integer{Value: 0})
+
+	done := make(chan bool)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			env := ExtendEnvironment(outer)
+			for j := 0; j < 100; j++ {
+				env.Define("local", &Integer{Value: int64(j)})
+				env.Set("shared", &Integer{Value: int64(i)})
+				env.Get("shared")
+			}
+			done <- true
+		}(i)
+	}
+
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+
+	if _, ok := outer.Get("local"); ok {
+		t.Errorf("local binding leaked into the outer environment")
+	}
+}
+
+func TestEnvironmentFrames(t *testing.T) {
+	global := NewEnvironment()
+	global.Define("g", &Integer{Value: 1})
+
+	layout := []string{"a", "b"}
+	outer := NewFrame(global, layout)
+	outer.SetAt(0, 1, "b", &Integer{Value: 2})
+	inner := ExtendEnvironment(outer)
+	inner.Define("c", &Integer{Value: 3})
+
+	if _, ok := inner.GetAt(1, 0); ok {
+		t.Errorf("unassigned slot has a value")
+	}
+
+	if _, ok := inner.Get("a"); ok {
+		t.Errorf("unassigned slot found by name")
+	}
+
+	for _, name := range []string{"b", "c", "g"} {
+		if _, ok := inner.Get(name); !ok {
+			t.Errorf("%s not found by name", name)
+		}
+	}
+
+	if value, ok := inner.GetAt(0, 0); !ok || value.Inspect() != "3" {
+		t.Errorf("wrong value in slot 0:0, got=%v", value)
+	}
+
+	// Defining a name laid out by the resolver uses its slot
+	outer.Define("a", &Integer{Value: 4})
+	if value, ok := inner.GetAt(1, 0); !ok || value.Inspect() != "4" {
+		t.Errorf("wrong value in slot 1:0, got=%v", value)
+	}
+
+	// The layout is shared by all frames of a block
+	other := NewFrame(global, layout)
+	other.Define("d", &Integer{Value: 5})
+	if len(layout) != 2 || NewFrame(global, layout).index("d") != -1 {
+		t.Errorf("layout was modified, got=%v", layout)
+	}
+	if _, ok := other.GetAt(0, 0); ok {
+		t.Errorf("frames share their values")
+	}
+}
+
+func TestEnvironmentBindings(t *testing.T) {
+	global := NewEnvironment()
+	global.Define("b", &Integer{Value: 2})
+	global.Define("a", &Integer{Value: 1})
+
+	frame := NewFrame(global, []string{"y", "x"})
+	frame.Define("x", &Integer{Value: 3})
+	frame.Define("z", &Integer{Value: 4})
+
+	bindings := func(env *Environment) string {
+		out := []string{}
+		for _, binding := range env.Bindings() {
+			out = append(out, binding.Name+"="+binding.Value.Inspect())
+		}
+		return strings.Join(out, " ")
+	}
+
+	// Unassigned slots are left out
+	if got := bindings(frame); got != "x=3 z=4" {
+		t.Errorf("wrong frame bindings, got=%q", got)
+	}
+
+	if frame.Outer() != global || global.Outer() != nil {
+		t.Errorf("wrong outer environments")
+	}
+
+	if got := bindings(global); got != "a=1 b=2" {
+		t.Errorf("wrong global bindings, got=%q", got)
+	}
+}
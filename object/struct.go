@@ -0,0 +1,231 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ApplyMethod calls a method defined in Firework, it is set by the evaluator
+// so that the protocol methods of structs can be used from here.
+var ApplyMethod func(method Object, args []Object) Object
+
+// StructType is declared by a struct statement, calling it constructs a
+// Struct with the arguments as its fields in order.
+type StructType struct {
+	Name   string
+	Fields []string
+	// Added by impl blocks, the receiver is passed as the first argument
+	Methods map[string]Object
+	Traits  map[*Trait]bool
+}
+
+func NewStructType(name string, fields []string) *StructType {
+	return &StructType{Name: name, Fields: fields, Methods: map[string]Object{}, Traits: map[*Trait]bool{}}
+}
+
+func (st *StructType) Inspect() string  { return "<struct " + st.Name + ">" }
+func (st *StructType) Type() ObjectType { return STRUCT_TYPE_OBJ }
+
+func (st *StructType) HasField(name string) bool {
+	for _, field := range st.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Trait lists the methods a struct type must have to implement it.
+type Trait struct {
+	Name    string
+	Methods []string
+}
+
+func (t *Trait) Inspect() string  { return "<trait " + t.Name + ">" }
+func (t *Trait) Type() ObjectType { return TRAIT_OBJ }
+
+// Struct may be shared by spawned tasks, so its fields are guarded.
+type Struct struct {
+	Definition *StructType
+	mu         sync.RWMutex
+	values     map[string]Object
+}
+
+func NewStruct(definition *StructType, values []Object) *Struct {
+	s := &Struct{Definition: definition, values: map[string]Object{}}
+	for i, field := range definition.Fields {
+		s.values[field] = values[i]
+	}
+	return s
+}
+
+// callMethod calls the method name of s, ok is false if there is none.
+func (s *Struct) callMethod(name string, args ...Object) (Object, bool) {
+	method, ok := s.Definition.Methods[name]
+	if !ok {
+		return nil, false
+	}
+
+	return ApplyMethod(method, append([]Object{s}, args...)), true
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+
+// Inspect uses the to_string method if there is one, the fields are shown
+// if it fails.
+func (s *Struct) Inspect() string {
+	str, _ := s.inspect()
+	return str
+}
+
+func (s *Struct) inspect() (string, Object) {
+	var err Object
+
+	if result, ok := s.callMethod("to_string"); ok {
+		if result == nil || result.Type() != ERROR_OBJ {
+			if str, ok := result.(*String); ok {
+				return str.Value, nil
+			}
+			return Inspect(result)
+		}
+		err = result
+	}
+
+	var out bytes.Buffer
+
+	values := []Object{}
+	for _, field := range s.Definition.Fields {
+		value, _ := s.Get(field)
+		values = append(values, value)
+	}
+	strs, fieldErr := inspectAll(values)
+	if err == nil {
+		err = fieldErr
+	}
+
+	fields := []string{}
+	for i, field := range s.Definition.Fields {
+		fields = append(fields, field+": "+strs[i])
+	}
+
+	out.WriteString(s.Definition.Name)
+	if len(fields) == 0 {
+		out.WriteString(" {}")
+		return out.String(), err
+	}
+
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(" }")
+
+	return out.String(), err
+}
+
+// Equals uses the equals method if there is one, otherwise structs of the
+// same type are equal if all their fields are.
+func (s *Struct) Equals(other Object) bool {
+	equal, _ := s.equals(other)
+	return equal
+}
+
+func (s *Struct) equals(other Object) (bool, Object) {
+	if result, ok := s.callMethod("equals", other); ok {
+		if result != nil && result.Type() == ERROR_OBJ {
+			return false, result
+		}
+		b, ok := result.(*Boolean)
+		return ok && b.Value, nil
+	}
+
+	otherStruct, ok := other.(*Struct)
+	if !ok || otherStruct.Definition != s.Definition {
+		return false, nil
+	}
+
+	for _, field := range s.Definition.Fields {
+		value, _ := s.Get(field)
+		otherValue, _ := otherStruct.Get(field)
+		if equal, err := Equal(value, otherValue); !equal || err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Hash uses the hash method, structs without one are not hashable. Its
+// result is checked by HashError before a struct is used as key.
+func (s *Struct) Hash() HashKey {
+	key, _ := s.hash()
+	return key
+}
+
+// hash calls the hash method, which must return an INTEGER.
+func (s *Struct) hash() (HashKey, Object) {
+	key := HashKey{Type: s.Type()}
+
+	result, ok := s.callMethod("hash")
+	if !ok {
+		return key, nil
+	}
+
+	if result != nil && result.Type() == ERROR_OBJ {
+		return key, result
+	}
+
+	integer, ok := result.(Hashable)
+	if !ok || result.Type() != INTEGER_OBJ {
+		got := ObjectType(NULL_OBJ)
+		if result != nil {
+			got = result.Type()
+		}
+		return key, &Error{Message: fmt.Sprintf("Protocol method `hash` of %s must return INTEGER, got %s", s.Definition.Name, got)}
+	}
+
+	key.Value = integer.Hash().Value
+	return key, nil
+}
+
+// HashError returns the error of hashing key, which is a struct whose hash
+// method fails or does not return an INTEGER, or a tuple holding one.
+func HashError(key Hashable) Object {
+	switch key := key.(type) {
+	case *Struct:
+		_, err := key.hash()
+		return err
+	case *Tuple:
+		for _, e := range key.Elements {
+			// Elements are checked by AsHashable before
+			if err := HashError(e.(Hashable)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Get returns the value of the field name, ok is false if there is no such
+// field.
+func (s *Struct) Get(name string) (Object, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// Set assigns an existing field, ok is false if there is no such field.
+func (s *Struct) Set(name string, value Object) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.values[name]; !ok {
+		return false
+	}
+
+	s.values[name] = value
+	return true
+}
@@ -0,0 +1,178 @@
+package optimizer
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/vita-dounai/Firework/ast"
+)
+
+// isLiteral reports whether expression evaluates to a constant without side
+// effects.
+func isLiteral(expression ast.Expression) bool {
+	switch expression.(type) {
+	case *ast.IntegerLiteral, *ast.BigIntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	default:
+		return false
+	}
+}
+
+// truthiness reports whether a literal condition holds, ok is false for
+// anything but a literal.
+func truthiness(condition ast.Expression) (value bool, ok bool) {
+	if boolean, isBoolean := condition.(*ast.Boolean); isBoolean {
+		return boolean.Value, true
+	}
+
+	// Only false and null are falsy
+	return true, isLiteral(condition)
+}
+
+// foldPrefix evaluates a prefix operator applied to a literal, ok is false
+// when the operator is left to the evaluator.
+func foldPrefix(operator string, right ast.Expression) (ast.Expression, bool) {
+	switch right := right.(type) {
+	case *ast.IntegerLiteral:
+		switch operator {
+		case "!":
+			return &ast.Boolean{Value: right.Value == 0}, true
+		case "-":
+			if right.Value == math.MinInt64 {
+				return nil, false
+			}
+			return &ast.IntegerLiteral{Value: -right.Value}, true
+		case "~":
+			return &ast.IntegerLiteral{Value: ^right.Value}, true
+		}
+	case *ast.Boolean:
+		if operator == "!" {
+			return &ast.Boolean{Value: !right.Value}, true
+		}
+	case *ast.StringLiteral:
+		if operator == "!" {
+			return &ast.Boolean{Value: false}, true
+		}
+	}
+
+	return nil, false
+}
+
+// foldInfix evaluates an infix operator applied to two literals of the same
+// type. Operations the evaluator would fail or widen to big integers are left
+// to it.
+func foldInfix(operator string, left, right ast.Expression) (ast.Expression, bool) {
+	switch left := left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := right.(*ast.IntegerLiteral); ok {
+			return foldInteger(operator, left.Value, right.Value)
+		}
+	case *ast.StringLiteral:
+		if right, ok := right.(*ast.StringLiteral); ok {
+			return foldString(operator, left.Value, right.Value)
+		}
+	case *ast.Boolean:
+		if right, ok := right.(*ast.Boolean); ok {
+			switch operator {
+			case "==":
+				return &ast.Boolean{Value: left.Value == right.Value}, true
+			case "!=":
+				return &ast.Boolean{Value: left.Value != right.Value}, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// integer returns value as a literal if it fits in int64.
+func integer(value *big.Int) (ast.Expression, bool) {
+	if !value.IsInt64() {
+		return nil, false
+	}
+	return &ast.IntegerLiteral{Value: value.Int64()}, true
+}
+
+func foldInteger(operator string, leftValue, rightValue int64) (ast.Expression, bool) {
+	left, right := big.NewInt(leftValue), big.NewInt(rightValue)
+
+	switch operator {
+	case "+":
+		return integer(left.Add(left, right))
+	case "-":
+		return integer(left.Sub(left, right))
+	case "*":
+		return integer(left.Mul(left, right))
+	case "**":
+		// Large powers overflow, negative ones are left to the evaluator
+		if rightValue < 0 || rightValue > 64 {
+			return nil, false
+		}
+		return integer(left.Exp(left, right, nil))
+	case "/":
+		if rightValue == 0 || leftValue == math.MinInt64 && rightValue == -1 {
+			return nil, false
+		}
+		return &ast.IntegerLiteral{Value: leftValue / rightValue}, true
+	case "%":
+		if rightValue == 0 {
+			return nil, false
+		}
+
+		if rightValue == -1 {
+			return &ast.IntegerLiteral{Value: 0}, true
+		}
+		return &ast.IntegerLiteral{Value: leftValue % rightValue}, true
+	case "&":
+		return &ast.IntegerLiteral{Value: leftValue & rightValue}, true
+	case "^":
+		return &ast.IntegerLiteral{Value: leftValue ^ rightValue}, true
+	case "bor":
+		return &ast.IntegerLiteral{Value: leftValue | rightValue}, true
+	case "<<":
+		if rightValue < 0 || rightValue > 64 {
+			return nil, false
+		}
+		return integer(left.Lsh(left, uint(rightValue)))
+	case ">>":
+		if rightValue < 0 {
+			return nil, false
+		}
+
+		if rightValue > 63 {
+			rightValue = 63
+		}
+		return &ast.IntegerLiteral{Value: leftValue >> uint(rightValue)}, true
+	case ">":
+		return &ast.Boolean{Value: leftValue > rightValue}, true
+	case ">=":
+		return &ast.Boolean{Value: leftValue >= rightValue}, true
+	case "<":
+		return &ast.Boolean{Value: leftValue < rightValue}, true
+	case "<=":
+		return &ast.Boolean{Value: leftValue <= rightValue}, true
+	case "==":
+		return &ast.Boolean{Value: leftValue == rightValue}, true
+	case "!=":
+		return &ast.Boolean{Value: leftValue != rightValue}, true
+	default:
+		return nil, false
+	}
+}
+
+func foldString(operator string, leftValue, rightValue string) (ast.Expression, bool) {
+	switch operator {
+	case "+":
+		return &ast.StringLiteral{Value: leftValue + rightValue}, true
+	case "<":
+		return &ast.Boolean{Value: leftValue < rightValue}, true
+	case ">":
+		return &ast.Boolean{Value: leftValue > rightValue}, true
+	case "==":
+		return &ast.Boolean{Value: leftValue == rightValue}, true
+	case "!=":
+		return &ast.Boolean{Value: leftValue != rightValue}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,299 @@
+package optimizer
+
+import (
+	"github.com/vita-dounai/Firework/ast"
+)
+
+// Level selects the optimizations applied to a program.
+type Level int
+
+const (
+	// NONE leaves programs untouched
+	NONE Level = iota
+	// FOLD evaluates operators applied to literals
+	FOLD
+	// FULL also removes unreachable code and redundant blocks
+	FULL
+)
+
+type optimizer struct {
+	level Level
+}
+
+// Optimize rewrites a macro expanded program in place, the optimized program
+// evaluates to the same results. Its frames have to be laid out again by the
+// resolver.
+func Optimize(program *ast.Program, level Level) *ast.Program {
+	if level <= NONE {
+		return program
+	}
+
+	o := &optimizer{level: level}
+	program.Statements = o.optimizeStatements(program.Statements)
+	return program
+}
+
+func unwrapExport(statement ast.Statement) ast.Statement {
+	if export, ok := statement.(*ast.ExportStatement); ok {
+		return export.Statement
+	}
+	return statement
+}
+
+// declares reports whether a statement binds a name in its frame.
+func declares(statement ast.Statement) bool {
+	switch unwrapExport(statement).(type) {
+	case *ast.AssignStatement, *ast.FunctionStatement, *ast.StructStatement,
+		*ast.TraitStatement, *ast.ImportStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// jumps reports whether the statements after statement are unreachable.
+func jumps(statement ast.Statement) bool {
+	switch statement.(type) {
+	case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// isInert reports whether a statement does nothing, its value is only used
+// when it is the last one.
+func isInert(statement ast.Statement) bool {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		if isLiteral(statement.Expression) {
+			return true
+		}
+
+		ie, ok := statement.Expression.(*ast.IfExpression)
+		if !ok || ie.Alternative != nil {
+			return false
+		}
+
+		holds, ok := truthiness(ie.Condition)
+		return ok && !holds
+	case *ast.WhileStatement:
+		holds, ok := truthiness(statement.Condition)
+		return ok && !holds
+	default:
+		return false
+	}
+}
+
+// inlinable reports whether the statements of a nested block can run in the
+// enclosing frame, which they can when they define nothing in their own.
+func inlinable(block *ast.BlockStatement, last bool) bool {
+	if last && len(block.Statements) == 0 {
+		return false
+	}
+
+	for _, statement := range block.Statements {
+		if declares(statement) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *optimizer) optimizeStatements(statements []ast.Statement) []ast.Statement {
+	optimized := []ast.Statement{}
+
+	for i, statement := range statements {
+		statement = o.optimizeStatement(statement)
+		if o.level < FULL {
+			optimized = append(optimized, statement)
+			continue
+		}
+
+		last := i == len(statements)-1
+		if nested, ok := statement.(*ast.BlockStatement); ok && inlinable(nested, last) {
+			optimized = append(optimized, nested.Statements...)
+		} else if last || !isInert(statement) {
+			optimized = append(optimized, statement)
+		}
+
+		if len(optimized) > 0 && jumps(optimized[len(optimized)-1]) {
+			// Functions declared after are still hoisted
+			for _, rest := range statements[i+1:] {
+				if _, ok := unwrapExport(rest).(*ast.FunctionStatement); ok {
+					optimized = append(optimized, o.optimizeStatement(rest))
+				}
+			}
+			break
+		}
+	}
+
+	return optimized
+}
+
+func (o *optimizer) optimizeBlock(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = o.optimizeStatements(block.Statements)
+	}
+}
+
+func (o *optimizer) optimizeStatement(statement ast.Statement) ast.Statement {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		statement.Expression = o.optimizeExpression(statement.Expression)
+
+		// The if of a statement is replaced by its branch taken
+		if ie, ok := statement.Expression.(*ast.IfExpression); ok && o.level >= FULL {
+			if holds, ok := truthiness(ie.Condition); ok && holds {
+				return ie.Consequence
+			}
+		}
+	case *ast.AssignStatement:
+		statement.Value = o.optimizeExpression(statement.Value)
+	case *ast.FieldAssignStatement:
+		statement.Target.Object = o.optimizeExpression(statement.Target.Object)
+		statement.Value = o.optimizeExpression(statement.Value)
+	case *ast.ReturnStatement:
+		statement.ReturnValue = o.optimizeExpression(statement.ReturnValue)
+	case *ast.FunctionStatement:
+		o.optimizeBlock(statement.Function.Body)
+	case *ast.ImplStatement:
+		for _, method := range statement.Methods {
+			o.optimizeBlock(method.Body)
+		}
+	case *ast.ExportStatement:
+		statement.Statement = o.optimizeStatement(statement.Statement)
+	case *ast.BlockStatement:
+		o.optimizeBlock(statement)
+	case *ast.WhileStatement:
+		statement.Condition = o.optimizeExpression(statement.Condition)
+		o.optimizeBlock(statement.Body)
+	case *ast.ForStatement:
+		statement.Iterable = o.optimizeExpression(statement.Iterable)
+		o.optimizeBlock(statement.Body)
+	}
+
+	return statement
+}
+
+func (o *optimizer) optimizeExpressions(expressions []ast.Expression) {
+	for i, expression := range expressions {
+		expressions[i] = o.optimizeExpression(expression)
+	}
+}
+
+func (o *optimizer) optimizeExpression(expression ast.Expression) ast.Expression {
+	switch node := expression.(type) {
+	case *ast.InterpolatedString:
+		o.optimizeExpressions(node.Expressions)
+	case *ast.PrefixExpression:
+		node.Right = o.optimizeExpression(node.Right)
+		if folded, ok := foldPrefix(node.Operator, node.Right); ok {
+			return folded
+		}
+	case *ast.InfixExpression:
+		node.Left = o.optimizeExpression(node.Left)
+		node.Right = o.optimizeExpression(node.Right)
+		if folded, ok := foldInfix(node.Operator, node.Left, node.Right); ok {
+			return folded
+		}
+	case *ast.IfExpression:
+		return o.optimizeIf(node)
+	case *ast.MatchExpression:
+		node.Subject = o.optimizeExpression(node.Subject)
+		for _, arm := range node.Arms {
+			arm.Guard = o.optimizeExpression(arm.Guard)
+			arm.Body = o.optimizeBody(arm.Body)
+		}
+	case *ast.SelectExpression:
+		for _, sc := range node.Cases {
+			if sc.Call != nil {
+				o.optimizeCall(sc.Call)
+			}
+			sc.Body = o.optimizeBody(sc.Body)
+		}
+	case *ast.FunctionLiteral:
+		o.optimizeBlock(node.Body)
+	case *ast.CallExpression:
+		o.optimizeCall(node)
+	case *ast.SpawnExpression:
+		o.optimizeCall(node.Call)
+	case *ast.ArrayLiteral:
+		o.optimizeExpressions(node.Elements)
+	case *ast.TupleLiteral:
+		o.optimizeExpressions(node.Elements)
+	case *ast.MapLiteral:
+		for _, pair := range node.Pairs {
+			pair.Key = o.optimizeExpression(pair.Key)
+			pair.Value = o.optimizeExpression(pair.Value)
+		}
+	case *ast.IndexExpression:
+		node.Left = o.optimizeExpression(node.Left)
+		node.Index = o.optimizeExpression(node.Index)
+	case *ast.SliceExpression:
+		node.Left = o.optimizeExpression(node.Left)
+		node.Start = o.optimizeExpression(node.Start)
+		node.End = o.optimizeExpression(node.End)
+		node.Step = o.optimizeExpression(node.Step)
+	case *ast.MemberExpression:
+		node.Object = o.optimizeExpression(node.Object)
+	case *ast.YieldExpression:
+		node.Value = o.optimizeExpression(node.Value)
+	case *ast.SpreadExpression:
+		node.Value = o.optimizeExpression(node.Value)
+	}
+
+	return expression
+}
+
+func (o *optimizer) optimizeCall(call *ast.CallExpression) {
+	if name, ok := call.Function.(*ast.Identifier); ok && name.Value == "quote" {
+		// Quoted code is returned as written
+		return
+	}
+
+	call.Function = o.optimizeExpression(call.Function)
+	o.optimizeExpressions(call.Arguments)
+}
+
+// optimizeBody optimizes the body of a match arm or select case.
+func (o *optimizer) optimizeBody(body ast.Node) ast.Node {
+	switch body := body.(type) {
+	case *ast.BlockStatement:
+		o.optimizeBlock(body)
+	case ast.Expression:
+		return o.optimizeExpression(body)
+	}
+	return body
+}
+
+// optimizeIf drops the branch a literal condition never takes, a branch left
+// holding a single expression replaces the if.
+func (o *optimizer) optimizeIf(ie *ast.IfExpression) ast.Expression {
+	ie.Condition = o.optimizeExpression(ie.Condition)
+	o.optimizeBlock(ie.Consequence)
+	o.optimizeBlock(ie.Alternative)
+
+	holds, ok := truthiness(ie.Condition)
+	if !ok || o.level < FULL {
+		return ie
+	}
+
+	taken := ie.Consequence
+	if !holds {
+		taken = ie.Alternative
+	}
+
+	if taken == nil {
+		empty := &ast.BlockStatement{Ident: ie.Consequence.Ident}
+		return &ast.IfExpression{Condition: &ast.Boolean{Value: false}, Consequence: empty}
+	}
+
+	if len(taken.Statements) == 1 {
+		if statement, ok := taken.Statements[0].(*ast.ExpressionStatement); ok && statement.Expression != nil {
+			return statement.Expression
+		}
+	}
+
+	return &ast.IfExpression{Condition: &ast.Boolean{Value: true}, Consequence: taken}
+}
@@ -0,0 +1,99 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/lexer"
+	"github.com/vita-dounai/Firework/parser"
+)
+
+func optimizeProgram(t *testing.T, input string, level Level) *ast.Program {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors for %q: %v", input, p.Errors())
+	}
+
+	return Optimize(program, level)
+}
+
+func TestConstantFolding(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7;"},
+		{"x + 2 * 3", "(x + 6);"},
+		{"-(1 - 3)", "2;"},
+		{"~0 bor 4 & 1 << 2", "-1;"},
+		{"7 / 2 + 7 % 2 + 2 ** 3", "12;"},
+		{"-7 >> 100", "-1;"},
+		{"\"fire\" + \"work\"", "\"firework\";"},
+		{"\"a\" < \"b\" == !false", "true;"},
+		{"!0 != !\"a\"", "true;"},
+		{"f(1 + 1, [2 * 2])", "f(2, [4]);"},
+		{"x = |a| { a * (2 + 2) }", "x = |a| {\n    (a * 4);\n};"},
+		// Left to the evaluator, which fails or widens them
+		{"1 / 0", "(1 / 0);"},
+		{"1 << -1", "(1 << -1);"},
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1);"},
+		{"2 ** 64", "(2 ** 64);"},
+		{"\"a\" <= \"b\"", "(\"a\" <= \"b\");"},
+		{"1 == \"1\"", "(1 == \"1\");"},
+		{"quote(1 + 2)", "quote((1 + 2));"},
+	}
+
+	for _, tt := range tests {
+		program := optimizeProgram(t, tt.input, FOLD)
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q, expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestDeadCodeElimination(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (1 < 2) { a } else { b }", "a;"},
+		{"if false { a } else { b }\nc", "b;c;"},
+		{"x = if true { a } else { b }", "x = a;"},
+		{"if false { a }\nb", "b;"},
+		{"if false { a }", "if false {\n}"},
+		{"while false { a }\nb", "b;"},
+		{"1\n\"a\"\nb", "b;"},
+		{"if true { x = 1 }", "{\n    x = 1;\n}"},
+		{"fn f() { return 1\ng() }", "fn f() {\n    return 1;\n}"},
+		{"fn f() { return g()\nh()\nfn g() { 1 } }", "fn f() {\n    return g();\n    fn g() {\n        1;\n    }\n}"},
+		{"for x in y { break\nx }", "for x in y {\n    break;\n}"},
+		{"for x in y { if true { continue }\nx }", "for x in y {\n    continue;\n}"},
+	}
+
+	for _, tt := range tests {
+		program := optimizeProgram(t, tt.input, FULL)
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q, expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestOptimizationLevels(t *testing.T) {
+	input := "if 1 > 2 { a } else { b }"
+
+	if program := optimizeProgram(t, input, NONE); program.String() != "if (1 > 2) {\n    a;\n} else {\n    b;\n}" {
+		t.Errorf("NONE changed the program: %q", program.String())
+	}
+
+	if program := optimizeProgram(t, input, FOLD); program.String() != "if false {\n    a;\n} else {\n    b;\n}" {
+		t.Errorf("FOLD did not only fold the program: %q", program.String())
+	}
+
+	if program := optimizeProgram(t, input, FULL); program.String() != "b;" {
+		t.Errorf("FULL did not remove the branch: %q", program.String())
+	}
+}
@@ -7,13 +7,22 @@ import (
 )
 
 const (
-	UNEXPECTED_EOF_ERROR    = "UNEXPECTED_EOF"
-	ILLEGAL_SYNTAX_ERROR    = "ILLEGAL_SYNTAX"
-	ILLEGAL_SYMBOL_ERROR    = "ILLEGAL_SYMBOL"
-	NOPREFIX_FUNCTION_ERROR = "NOPREFIX_FUNCTION"
-	ILLEGAL_INTEGER_ERROR   = "ILLEGAL_INTEGER"
-	ILLEGAL_BREAK_ERROR     = "ILLEGAL_BREAK"
-	ILLEGAL_CONTINUE_ERROR  = "ILLEGAL_CONTINUE"
+	UNEXPECTED_EOF_ERROR      = "UNEXPECTED_EOF"
+	ILLEGAL_SYNTAX_ERROR      = "ILLEGAL_SYNTAX"
+	ILLEGAL_SYMBOL_ERROR      = "ILLEGAL_SYMBOL"
+	NOPREFIX_FUNCTION_ERROR   = "NOPREFIX_FUNCTION"
+	ILLEGAL_INTEGER_ERROR     = "ILLEGAL_INTEGER"
+	ILLEGAL_BREAK_ERROR       = "ILLEGAL_BREAK"
+	ILLEGAL_CONTINUE_ERROR    = "ILLEGAL_CONTINUE"
+	UNTERMINATED_STRING_ERROR = "UNTERMINATED_STRING"
+	ILLEGAL_ESCAPE_ERROR      = "ILLEGAL_ESCAPE"
+	ILLEGAL_PATTERN_ERROR     = "ILLEGAL_PATTERN"
+	ILLEGAL_YIELD_ERROR       = "ILLEGAL_YIELD"
+	ILLEGAL_SPAWN_ERROR       = "ILLEGAL_SPAWN"
+	ILLEGAL_SELECT_CASE_ERROR = "ILLEGAL_SELECT_CASE"
+	ILLEGAL_EXPORT_ERROR      = "ILLEGAL_EXPORT"
+	DUPLICATE_FIELD_ERROR     = "DUPLICATE_FIELD"
+	DUPLICATE_BINDING_ERROR   = "DUPLICATE_BINDING"
 )
 
 type ParseError interface {
@@ -36,7 +45,7 @@ func (is *IllegalSyntax) Info() string {
 }
 
 type IllegalSymbol struct {
-	Symbol string
+	Token token.Token
 }
 
 func (is *IllegalSymbol) Type() string {
@@ -44,7 +53,36 @@ func (is *IllegalSymbol) Type() string {
 }
 
 func (is *IllegalSymbol) Info() string {
-	msg := fmt.Sprintf("symbol not recognized `%s`", is.Symbol)
+	msg := fmt.Sprintf("symbol not recognized `%s`, line: %d, column: %d",
+		is.Token.Literal, is.Token.Line, is.Token.Column)
+	return msg
+}
+
+type UnterminatedString struct {
+	Token token.Token
+}
+
+func (us *UnterminatedString) Type() string {
+	return UNTERMINATED_STRING_ERROR
+}
+
+func (us *UnterminatedString) Info() string {
+	msg := fmt.Sprintf("string literal not terminated, line: %d, column: %d",
+		us.Token.Line, us.Token.Column)
+	return msg
+}
+
+type IllegalEscape struct {
+	Token token.Token
+}
+
+func (ie *IllegalEscape) Type() string {
+	return ILLEGAL_ESCAPE_ERROR
+}
+
+func (ie *IllegalEscape) Info() string {
+	msg := fmt.Sprintf("unknown escape sequence `%s`, line: %d, column: %d",
+		ie.Token.Literal, ie.Token.Line, ie.Token.Column)
 	return msg
 }
 
@@ -97,6 +135,86 @@ func (ib *IllegalBreak) Info() string {
 	return msg
 }
 
+type IllegalYield struct{}
+
+func (iy *IllegalYield) Type() string {
+	return ILLEGAL_YIELD_ERROR
+}
+
+func (iy *IllegalYield) Info() string {
+	return "yield should be used in function body"
+}
+
+type IllegalSpawn struct {
+	Token token.Token
+}
+
+func (is *IllegalSpawn) Type() string {
+	return ILLEGAL_SPAWN_ERROR
+}
+
+func (is *IllegalSpawn) Info() string {
+	msg := fmt.Sprintf("spawn should be followed by a function call, line: %d, column: %d",
+		is.Token.Line, is.Token.Column)
+	return msg
+}
+
+type IllegalSelectCase struct {
+	Token token.Token
+}
+
+func (isc *IllegalSelectCase) Type() string {
+	return ILLEGAL_SELECT_CASE_ERROR
+}
+
+func (isc *IllegalSelectCase) Info() string {
+	msg := fmt.Sprintf("select case should be recv(channel), send(channel, value) or _, line: %d, column: %d",
+		isc.Token.Line, isc.Token.Column)
+	return msg
+}
+
+type IllegalExport struct {
+	Token token.Token
+}
+
+func (ie *IllegalExport) Type() string {
+	return ILLEGAL_EXPORT_ERROR
+}
+
+func (ie *IllegalExport) Info() string {
+	msg := fmt.Sprintf("export should precede a top-level assignment or function declaration, line: %d, column: %d",
+		ie.Token.Line, ie.Token.Column)
+	return msg
+}
+
+type DuplicateField struct {
+	Token token.Token
+}
+
+func (df *DuplicateField) Type() string {
+	return DUPLICATE_FIELD_ERROR
+}
+
+func (df *DuplicateField) Info() string {
+	msg := fmt.Sprintf("duplicate name `%s`, line: %d, column: %d",
+		df.Token.Literal, df.Token.Line, df.Token.Column)
+	return msg
+}
+
+type DuplicateBinding struct {
+	Token token.Token
+}
+
+func (db *DuplicateBinding) Type() string {
+	return DUPLICATE_BINDING_ERROR
+}
+
+func (db *DuplicateBinding) Info() string {
+	msg := fmt.Sprintf("`%s` is bound twice by the pattern, line: %d, column: %d",
+		db.Token.Literal, db.Token.Line, db.Token.Column)
+	return msg
+}
+
 type IllegalContinue struct{}
 
 func (ib *IllegalContinue) Type() string {
@@ -107,3 +225,17 @@ func (ib *IllegalContinue) Info() string {
 	msg := fmt.Sprintf("continue should be used in loop statement")
 	return msg
 }
+
+type IllegalPattern struct {
+	Token token.Token
+}
+
+func (ip *IllegalPattern) Type() string {
+	return ILLEGAL_PATTERN_ERROR
+}
+
+func (ip *IllegalPattern) Info() string {
+	msg := fmt.Sprintf("`%s` is not a valid pattern, line: %d, column: %d",
+		ip.Token.Literal, ip.Token.Line, ip.Token.Column)
+	return msg
+}
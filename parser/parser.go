@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"math/big"
 	"strconv"
 
 	"github.com/vita-dounai/Firework/ast"
@@ -18,38 +19,46 @@ const (
 	LOWEST
 	EQUALS      // ==
 	LESSGREATER // < or >
+	BITWISE_OR  // bor
+	BITWISE_XOR // ^
+	BITWISE_AND // &
+	SHIFT       // << or >>
 	SUM         // +
 	PRODUCT     // *
 	EXP         // **
-	PREFIX      // - or !
+	PREFIX      // -, ! or ~
 	CALL        // funcion call
 	INDEX       // array[index]
 )
 
 var (
-	BREAK_STATEMENT    = &ast.BreakStatement{}
-	CONTINUE_STATEMENT = &ast.ContinueStatement{}
-
 	UNEXPECTED_EOF   = &UnexpectedEOF{}
 	ILLEGAL_BREAK    = &IllegalBreak{}
 	ILLEGAL_CONTINUE = &IllegalContinue{}
+	ILLEGAL_YIELD    = &IllegalYield{}
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.LTE:      LESSGREATER,
-	token.GTE:      LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.PERCENT:  PRODUCT,
-	token.EXP:      EXP,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.LTE:       LESSGREATER,
+	token.GTE:       LESSGREATER,
+	token.BOR:       BITWISE_OR,
+	token.CARET:     BITWISE_XOR,
+	token.AMPERSAND: BITWISE_AND,
+	token.LSHIFT:    SHIFT,
+	token.RSHIFT:    SHIFT,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.PERCENT:   PRODUCT,
+	token.EXP:       EXP,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
+	token.DOT:       INDEX,
 }
 
 type Parser struct {
@@ -65,6 +74,12 @@ type Parser struct {
 
 	ident  int
 	inLoop int
+
+	// Functions being parsed, innermost last
+	functions []*ast.FunctionLiteral
+
+	// Names bound by the pattern being parsed
+	bindings map[string]bool
 }
 
 func (p *Parser) Init(l *lexer.Lexer) {
@@ -77,6 +92,7 @@ func (p *Parser) Init(l *lexer.Lexer) {
 	// Remove all previous parsing errors
 	p.errors = p.errors[0:0]
 	p.ident = 0
+	p.functions = p.functions[0:0]
 }
 
 func (p *Parser) nextToken() {
@@ -134,8 +150,25 @@ func (p *Parser) checkUnexpectedEOF() bool {
 	return false
 }
 
+// lexerError converts error tokens produced by the lexer to parse errors, it
+// returns nil for any other token.
+func lexerError(t token.Token) ParseError {
+	switch t.Type {
+	case token.ILLEGAL:
+		return &IllegalSymbol{Token: t}
+	case token.UNTERMINATED_STRING:
+		return &UnterminatedString{Token: t}
+	case token.ILLEGAL_ESCAPE:
+		return &IllegalEscape{Token: t}
+	default:
+		return nil
+	}
+}
+
 func (p *Parser) peekError(tokenType token.TokenType) {
-	if p.peekTokenIs(token.EOF) {
+	if err := lexerError(p.peekToken); err != nil {
+		p.errors = append(p.errors, err)
+	} else if p.peekTokenIs(token.EOF) {
 		if !p.checkUnexpectedEOF() {
 			p.errors = append(p.errors, UNEXPECTED_EOF)
 		}
@@ -169,14 +202,36 @@ func (p *Parser) parseOptionalSemicolon() ast.Statement {
 func (p *Parser) parseIdentifierCommon() ast.Statement {
 	if p.peekTokenIs(token.ASSIGN) {
 		// Assign statement
-		return p.parseAssignStatement()
+		if statement := p.parseAssignStatement(); statement != nil {
+			return statement
+		}
+		return nil
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseAnnotatedAssignStatement(&ast.Identifier{Value: p.curToken.Literal})
 	}
 
 	// Expression statement
 	return p.parseExpressionStatement()
 }
 
+func (p *Parser) curPosition() ast.Position {
+	return ast.Position{Line: p.curToken.Line, Column: p.curToken.Column}
+}
+
+// parseStatement parses a statement and records where it starts.
 func (p *Parser) parseStatement() ast.Statement {
+	position := p.curPosition()
+
+	statement := p.parseStatementKind()
+	if statement != nil {
+		statement.SetPos(position)
+	}
+	return statement
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.curToken.Type {
 	case token.SEMICOLON:
 		return p.parseOptionalSemicolon()
@@ -186,18 +241,37 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseReturnStatement()
 	case token.WHILE:
 		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
 	case token.LBRACE:
 		return p.parseBlockCommon()
 	case token.BREAK:
 		return p.parseBreakStatement()
 	case token.CONTINUE:
 		return p.parseContinueStatement()
+	case token.FN:
+		return p.parseFunctionStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.TRAIT:
+		return p.parseTraitStatement()
+	case token.IMPL:
+		return p.parseImplStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.FROM:
+		return p.parseFromImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseBlockCommon() ast.Statement {
+	// Where the first statement starts, unless it is parsed as a statement
+	first := ast.Position{Line: p.peekToken.Line, Column: p.peekToken.Column}
+
 	switch p.peekToken.Type {
 	case "return":
 		fallthrough
@@ -209,8 +283,21 @@ func (p *Parser) parseBlockCommon() ast.Statement {
 		fallthrough
 	case "continue":
 		fallthrough
+	case token.FN:
+		fallthrough
+	case token.STRUCT:
+		fallthrough
+	case token.TRAIT:
+		fallthrough
+	case token.IMPL:
+		fallthrough
+	case token.FOR:
+		fallthrough
 	case "}":
-		return p.parseBlockStatement()
+		if block := p.parseBlockStatement(); block != nil {
+			return block
+		}
+		return nil
 	case "{":
 		p.nextToken()
 		p.ident++
@@ -219,7 +306,7 @@ func (p *Parser) parseBlockCommon() ast.Statement {
 
 		if _, ok := nestedBlock.(*ast.BlockStatement); ok {
 			p.nextToken()
-			return p.parseBlockStatement2(nestedBlock)
+			return p.parseBlockStatement2(nestedBlock, first)
 		}
 
 		expressionStatement := nestedBlock.(*ast.ExpressionStatement)
@@ -240,23 +327,53 @@ func (p *Parser) parseBlockCommon() ast.Statement {
 		}
 
 		p.nextToken()
-		return p.parseBlockStatement2(nestedBlock)
+		return p.parseBlockStatement2(nestedBlock, first)
 	}
 
 	p.nextToken()
 	piece := p.parseExpression(LOWEST)
 
+	if member, ok := piece.(*ast.MemberExpression); ok && p.peekTokenIs(token.ASSIGN) {
+		p.ident++
+		fieldAssignStatement := p.parseFieldAssignStatement(member)
+		p.ident--
+
+		if fieldAssignStatement == nil {
+			return nil
+		}
+
+		p.nextToken()
+		return p.parseBlockStatement2(fieldAssignStatement, first)
+	}
+
 	if identifier, ok := piece.(*ast.Identifier); ok {
 		if p.peekTokenIs(token.ASSIGN) {
 			p.ident++
 			assignStatement := p.parseAssignStatement2(identifier)
 			p.ident--
 
+			if assignStatement == nil {
+				return nil
+			}
+
 			p.nextToken()
-			blockStatement := p.parseBlockStatement2(assignStatement)
+			blockStatement := p.parseBlockStatement2(assignStatement, first)
 			return blockStatement
 		}
 
+		if p.peekTokenIs(token.COLON) {
+			p.ident++
+			assignStatement := p.parseAnnotatedAssignStatement(identifier)
+			p.ident--
+
+			if assignStatement == nil {
+				return nil
+			}
+
+			p.nextToken()
+			return p.parseBlockStatement2(assignStatement, first)
+		}
+
 		// Skip optional semicolon
 		if p.peekTokenIs(token.SEMICOLON) {
 			p.nextToken()
@@ -266,7 +383,7 @@ func (p *Parser) parseBlockCommon() ast.Statement {
 		expressionStatement.Expression = identifier
 
 		p.nextToken()
-		return p.parseBlockStatement2(expressionStatement)
+		return p.parseBlockStatement2(expressionStatement, first)
 	}
 
 	if p.peekTokenIs(token.COLON) {
@@ -288,7 +405,7 @@ func (p *Parser) parseBlockCommon() ast.Statement {
 	expressionStatement.Expression = piece
 
 	p.nextToken()
-	return p.parseBlockStatement2(expressionStatement)
+	return p.parseBlockStatement2(expressionStatement, first)
 }
 
 func (p *Parser) parseMapLiteralCommon(mapLiteral *ast.MapLiteral) ast.Expression {
@@ -303,7 +420,7 @@ func (p *Parser) parseMapLiteralCommon(mapLiteral *ast.MapLiteral) ast.Expressio
 		p.nextToken()
 
 		value := p.parseExpression(LOWEST)
-		mapLiteral.Pairs[key] = value
+		mapLiteral.Pairs = append(mapLiteral.Pairs, &ast.MapPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -319,15 +436,14 @@ func (p *Parser) parseMapLiteralCommon(mapLiteral *ast.MapLiteral) ast.Expressio
 
 func (p *Parser) parseMapLiteral() ast.Expression {
 	mapLiteral := &ast.MapLiteral{}
-	mapLiteral.Pairs = make(map[ast.Expression]ast.Expression)
+	mapLiteral.Pairs = []*ast.MapPair{}
 
 	return p.parseMapLiteralCommon(mapLiteral)
 }
 
 func (p *Parser) parseMapLiteral2(firstKey ast.Expression, firstValue ast.Expression) ast.Expression {
 	mapLiteral := &ast.MapLiteral{}
-	mapLiteral.Pairs = make(map[ast.Expression]ast.Expression)
-	mapLiteral.Pairs[firstKey] = firstValue
+	mapLiteral.Pairs = []*ast.MapPair{{Key: firstKey, Value: firstValue}}
 
 	if p.curTokenIs(token.RBRACE) {
 		return mapLiteral
@@ -365,6 +481,36 @@ func (p *Parser) parseAssignStatement2(identifier *ast.Identifier) *ast.AssignSt
 	return p.parseAssignStatementCommon(statement)
 }
 
+// parseAnnotatedAssignStatement parses `name: type = value` from the colon.
+func (p *Parser) parseAnnotatedAssignStatement(name *ast.Identifier) ast.Statement {
+	statement := &ast.AssignStatement{Name: name}
+
+	p.nextToken()
+	if statement.Type = p.parseTypeAnnotation(); statement.Type == nil {
+		return nil
+	}
+
+	if assignStatement := p.parseAssignStatementCommon(statement); assignStatement != nil {
+		return assignStatement
+	}
+	return nil
+}
+
+func (p *Parser) parseFieldAssignStatement(target *ast.MemberExpression) ast.Statement {
+	// Skip `=`
+	p.nextToken()
+	p.nextToken()
+
+	statement := &ast.FieldAssignStatement{Target: target}
+	statement.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	statement := &ast.ReturnStatement{}
 
@@ -379,7 +525,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
-func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+func (p *Parser) parseWhileStatement() ast.Statement {
 	p.inLoop++
 	statement := &ast.WhileStatement{}
 
@@ -397,7 +543,122 @@ func (p *Parser) parseWhileStatement() *ast.WhileStatement {
 	return statement
 }
 
-func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+func (p *Parser) parseForStatement() ast.Statement {
+	statement := &ast.ForStatement{}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Variable = &ast.Identifier{Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+	if statement.Iterable == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.inLoop++
+	statement.Body = p.parseBlockStatement()
+	p.inLoop--
+
+	if statement.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseImportStatement() ast.Statement {
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	statement := &ast.ImportStatement{Path: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseFromImportStatement() ast.Statement {
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	statement := &ast.ImportStatement{Path: p.curToken.Literal, Names: []*ast.Identifier{}}
+
+	if !p.expectPeek(token.IMPORT) || !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Names = append(statement.Names, &ast.Identifier{Value: p.curToken.Literal})
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		statement.Names = append(statement.Names, &ast.Identifier{Value: p.curToken.Literal})
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	exportToken := p.curToken
+
+	if p.ident != 0 {
+		p.errors = append(p.errors, &IllegalExport{Token: exportToken})
+		return nil
+	}
+
+	p.nextToken()
+
+	var statement ast.Statement
+	switch {
+	case p.curTokenIs(token.FN):
+		statement = p.parseFunctionStatement()
+	case p.curTokenIs(token.STRUCT):
+		statement = p.parseStructStatement()
+	case p.curTokenIs(token.TRAIT):
+		statement = p.parseTraitStatement()
+	case p.curTokenIs(token.IDENTIFIER) && p.peekTokenIs(token.ASSIGN):
+		if assignStatement := p.parseAssignStatement(); assignStatement != nil {
+			statement = assignStatement
+		}
+	case p.curTokenIs(token.IDENTIFIER) && p.peekTokenIs(token.COLON):
+		statement = p.parseAnnotatedAssignStatement(&ast.Identifier{Value: p.curToken.Literal})
+	default:
+		p.errors = append(p.errors, &IllegalExport{Token: exportToken})
+		return nil
+	}
+
+	if statement == nil {
+		return nil
+	}
+
+	return &ast.ExportStatement{Statement: statement}
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
 	// Swallow optional semicolon first to avoid triggering extra no prefix function error
 	// when break statement is not in a loop statement
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -409,10 +670,10 @@ func (p *Parser) parseBreakStatement() *ast.BreakStatement {
 		return nil
 	}
 
-	return BREAK_STATEMENT
+	return &ast.BreakStatement{}
 }
 
-func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+func (p *Parser) parseContinueStatement() ast.Statement {
 	// Swallow optional semicolon first to avoid triggering extra no prefix function error
 	// when continue statement is not in a loop statement
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -424,14 +685,18 @@ func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
 		return nil
 	}
 
-	return CONTINUE_STATEMENT
+	return &ast.ContinueStatement{}
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	statement := &ast.ExpressionStatement{}
 
 	statement.Expression = p.parseExpression(LOWEST)
 
+	if member, ok := statement.Expression.(*ast.MemberExpression); ok && p.peekTokenIs(token.ASSIGN) {
+		return p.parseFieldAssignStatement(member)
+	}
+
 	// Skip optional semicolon
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -441,13 +706,16 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.Token) {
-	switch p.curToken.Type {
+	if err := lexerError(t); err != nil {
+		p.errors = append(p.errors, err)
+		return
+	}
+
+	switch t.Type {
 	case token.EOF:
 		if !p.checkUnexpectedEOF() {
 			p.errors = append(p.errors, UNEXPECTED_EOF)
 		}
-	case token.ILLEGAL:
-		p.errors = append(p.errors, &IllegalSymbol{p.curToken.Literal})
 	default:
 		p.errors = append(p.errors, &NoPrefixFunction{Token: t})
 	}
@@ -461,7 +729,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 
+	position := p.curPosition()
 	leftExp := prefix()
+	setPosition(leftExp, position)
 
 	return p.parseExpression2(precedence, leftExp)
 }
@@ -475,12 +745,25 @@ func (p *Parser) parseExpression2(precedence int, leftExp ast.Expression) ast.Ex
 
 		p.nextToken()
 
+		position := p.curPosition()
 		leftExp = infix(leftExp)
+		setPosition(leftExp, position)
 	}
 
 	return leftExp
 }
 
+// setPosition records where an expression starts, or where its operator is
+// for infix expressions, unless it is known already as for grouped ones.
+func setPosition(expression ast.Expression, position ast.Position) {
+	if node, ok := expression.(interface {
+		Pos() ast.Position
+		SetPos(ast.Position)
+	}); ok && node.Pos() == (ast.Position{}) {
+		node.SetPos(position)
+	}
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Value: p.curToken.Literal}
 }
@@ -502,6 +785,19 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			// `else if` is an alternative block holding only the nested if expression
+			p.nextToken()
+			p.ident++
+			alternative := &ast.BlockStatement{Ident: p.ident}
+			alternative.Statements = []ast.Statement{
+				&ast.ExpressionStatement{Position: p.curPosition(), Expression: p.parseIfExpression()},
+			}
+			p.ident--
+			expression.Alternative = alternative
+			return expression
+		}
+
 		p.expectPeek(token.LBRACE)
 		expression.Alternative = p.parseBlockStatement()
 	}
@@ -509,111 +805,690 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
-func (p *Parser) parseBlockStatementCommon(block *ast.BlockStatement) *ast.BlockStatement {
-	p.ident++
-	block.Ident = p.ident
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Arms: []*ast.MatchArm{}}
+	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) {
-		if p.curTokenIs(token.EOF) {
-			if !p.checkUnexpectedEOF() {
-				p.errors = append(p.errors, UNEXPECTED_EOF)
-			}
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
 			return nil
 		}
+		expression.Arms = append(expression.Arms, arm)
 
-		statement := p.parseStatement()
-		if statement != nil {
-			block.Statements = append(block.Statements, statement)
+		_, isBlock := arm.Body.(*ast.BlockStatement)
+		switch {
+		case p.peekTokenIs(token.COMMA):
+			p.nextToken()
+		case isBlock || p.peekTokenIs(token.RBRACE):
+			// The comma is optional after a block body and after the last arm
+		default:
+			p.peekError(token.COMMA)
+			return nil
 		}
-		p.nextToken()
 	}
 
-	p.ident--
-	return block
-}
-
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{}
-	block.Statements = []ast.Statement{}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
 
-	p.nextToken()
-	block = p.parseBlockStatementCommon(block)
-	return block
+	return expression
 }
 
-func (p *Parser) parseBlockStatement2(firstStatement ast.Statement) *ast.BlockStatement {
-	block := &ast.BlockStatement{}
-	block.Statements = []ast.Statement{firstStatement}
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{}
 
-	block = p.parseBlockStatementCommon(block)
-	return block
-}
+	p.bindings = map[string]bool{}
+	arm.Pattern = p.parsePattern()
+	if arm.Pattern == nil {
+		return nil
+	}
 
-func (p *Parser) parseIntegerLiteral() ast.Expression {
-	expression := &ast.IntegerLiteral{}
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-	if err != nil {
-		p.errors = append(p.errors, &IllegalInteger{Literal: p.curToken.Literal})
+	if !p.expectPeek(token.FAT_ARROW) {
 		return nil
 	}
 
-	expression.Value = value
-	return expression
-}
+	arm.Body = p.parseArmBody()
+	if arm.Body == nil {
+		return nil
+	}
 
-func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Value: p.curToken.Literal}
+	return arm
 }
 
-func (p *Parser) parsePrefixExpression() ast.Expression {
-	expression := &ast.PrefixExpression{Operator: p.curToken.Literal}
-
+// parseArmBody parses the body after `=>`, either a block or an expression.
+func (p *Parser) parseArmBody() ast.Node {
 	p.nextToken()
 
-	expression.Right = p.parseExpression(PREFIX)
-
-	return expression
-}
-
-func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	expression := &ast.InfixExpression{
-		Operator: p.curToken.Literal,
-		Left:     left,
+	if p.curTokenIs(token.LBRACE) {
+		if body := p.parseBlockStatement(); body != nil {
+			return body
+		}
+		return nil
 	}
 
-	precedence := p.curPrecedence()
-	p.nextToken()
-	expression.Right = p.parseExpression(precedence)
-
-	return expression
+	if body := p.parseExpression(LOWEST); body != nil {
+		return body
+	}
+	return nil
 }
 
-func (p *Parser) parseGroupedExpression() ast.Expression {
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	spawnToken := p.curToken
+	errorCount := len(p.errors)
 	p.nextToken()
 
-	expression := p.parseExpression(LOWEST)
-
-	if !p.expectPeek(token.RPAREN) {
+	call, ok := p.parseExpression(PREFIX).(*ast.CallExpression)
+	if !ok {
+		if len(p.errors) == errorCount {
+			p.errors = append(p.errors, &IllegalSpawn{Token: spawnToken})
+		}
 		return nil
 	}
 
-	return expression
+	return &ast.SpawnExpression{Call: call}
 }
 
-func (p *Parser) parseFunctionLiteral() ast.Expression {
-	function := &ast.FunctionLiteral{}
-
-	function.Parameters = p.parseFunctionParameters(token.VERTICAL)
+func (p *Parser) parseSelectExpression() ast.Expression {
+	expression := &ast.SelectExpression{Cases: []*ast.SelectCase{}}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	function.Body = p.parseBlockStatement()
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
 
-	return function
-}
+		selectCase := p.parseSelectCase()
+		if selectCase == nil {
+			return nil
+		}
+		expression.Cases = append(expression.Cases, selectCase)
+
+		_, isBlock := selectCase.Body.(*ast.BlockStatement)
+		switch {
+		case p.peekTokenIs(token.COMMA):
+			p.nextToken()
+		case isBlock || p.peekTokenIs(token.RBRACE):
+			// The comma is optional after a block body and after the last case
+		default:
+			p.peekError(token.COMMA)
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	selectCase := &ast.SelectCase{}
+	caseToken := p.curToken
+	errorCount := len(p.errors)
+
+	if p.curTokenIs(token.IDENTIFIER) && p.peekTokenIs(token.ASSIGN) {
+		selectCase.Name = &ast.Identifier{Value: p.curToken.Literal}
+		p.nextToken()
+		p.nextToken()
+		caseToken = p.curToken
+	}
+
+	if selectCase.Name != nil || !p.curTokenIs(token.IDENTIFIER) || p.curToken.Literal != "_" {
+		call, ok := p.parseExpression(LOWEST).(*ast.CallExpression)
+		if !ok || !isSelectCall(call, selectCase.Name == nil) {
+			if len(p.errors) == errorCount {
+				p.errors = append(p.errors, &IllegalSelectCase{Token: caseToken})
+			}
+			return nil
+		}
+		selectCase.Call = call
+	}
+
+	if !p.expectPeek(token.FAT_ARROW) {
+		return nil
+	}
+
+	selectCase.Body = p.parseArmBody()
+	if selectCase.Body == nil {
+		return nil
+	}
+
+	return selectCase
+}
+
+// isSelectCall reports whether call is `recv(channel)` or, if allowed,
+// `send(channel, value)`.
+func isSelectCall(call *ast.CallExpression, allowSend bool) bool {
+	function, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+
+	switch function.Value {
+	case "recv":
+		return len(call.Arguments) == 1
+	case "send":
+		return allowSend && len(call.Arguments) == 2
+	default:
+		return false
+	}
+}
+
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENTIFIER:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{}
+		}
+		name := p.parseBinding()
+		if name == nil {
+			return nil
+		}
+		return &ast.BindingPattern{Name: name}
+	case token.INT, token.MINUS, token.STRING, token.TRUE, token.FALSE:
+		return p.parseLiteralOrRangePattern()
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseMapPattern()
+	default:
+		if err := lexerError(p.curToken); err != nil {
+			p.errors = append(p.errors, err)
+		} else if p.curTokenIs(token.EOF) {
+			if !p.checkUnexpectedEOF() {
+				p.errors = append(p.errors, UNEXPECTED_EOF)
+			}
+		} else {
+			p.errors = append(p.errors, &IllegalPattern{Token: p.curToken})
+		}
+		return nil
+	}
+}
+
+// parseBinding parses a name bound by the pattern, which may bind it only once
+// as the values matched could differ.
+func (p *Parser) parseBinding() *ast.Identifier {
+	if p.bindings[p.curToken.Literal] {
+		p.errors = append(p.errors, &DuplicateBinding{Token: p.curToken})
+		return nil
+	}
+	p.bindings[p.curToken.Literal] = true
+
+	return &ast.Identifier{Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseLiteralOrRangePattern() ast.Pattern {
+	low := p.parseExpression(PREFIX)
+	if low == nil {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.DOTDOT) && !p.peekTokenIs(token.DOTDOT_EQ) {
+		return &ast.LiteralPattern{Value: low}
+	}
+
+	p.nextToken()
+	pattern := &ast.RangePattern{Low: low, Inclusive: p.curTokenIs(token.DOTDOT_EQ)}
+
+	p.nextToken()
+	pattern.High = p.parseExpression(PREFIX)
+	if pattern.High == nil {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Elements: []ast.Pattern{}}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.DOTDOT) {
+			// Rest pattern must be the last element
+			pattern.HasRest = true
+			if p.peekTokenIs(token.IDENTIFIER) {
+				p.nextToken()
+				pattern.Rest = p.parseBinding()
+				if pattern.Rest == nil {
+					return nil
+				}
+			}
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseMapPattern() ast.Pattern {
+	pattern := &ast.MapPattern{Pairs: []*ast.MapPatternPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		key := p.parseExpression(PREFIX)
+		if key == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+
+		pattern.Pairs = append(pattern.Pairs, &ast.MapPatternPair{Key: key, Pattern: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseBlockStatementCommon(block *ast.BlockStatement) *ast.BlockStatement {
+	p.ident++
+	block.Ident = p.ident
+
+	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			if !p.checkUnexpectedEOF() {
+				p.errors = append(p.errors, UNEXPECTED_EOF)
+			}
+			return nil
+		}
+
+		statement := p.parseStatement()
+		if statement != nil {
+			block.Statements = append(block.Statements, statement)
+		}
+		p.nextToken()
+	}
+
+	p.ident--
+	return block
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+	block = p.parseBlockStatementCommon(block)
+	return block
+}
+
+// parseBlockStatement2 parses the rest of a block whose first statement,
+// starting at position, is already parsed.
+func (p *Parser) parseBlockStatement2(firstStatement ast.Statement, position ast.Position) ast.Statement {
+	firstStatement.SetPos(position)
+
+	block := &ast.BlockStatement{}
+	block.Statements = []ast.Statement{firstStatement}
+
+	if block = p.parseBlockStatementCommon(block); block != nil {
+		return block
+	}
+	return nil
+}
+
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	expression := &ast.IntegerLiteral{}
+
+	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if err != nil {
+		if numError, ok := err.(*strconv.NumError); ok && numError.Err == strconv.ErrRange {
+			if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+				return &ast.BigIntegerLiteral{Value: bigValue}
+			}
+		}
+
+		p.errors = append(p.errors, &IllegalInteger{Literal: p.curToken.Literal})
+		return nil
+	}
+
+	expression.Value = value
+	return expression
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	expression := &ast.InterpolatedString{Strings: []string{p.curToken.Literal}}
+
+	for {
+		p.nextToken()
+		expression.Expressions = append(expression.Expressions, p.parseExpression(LOWEST))
+
+		switch {
+		case p.peekTokenIs(token.STRING_TAIL):
+			p.nextToken()
+			expression.Strings = append(expression.Strings, p.curToken.Literal)
+			return expression
+		case p.peekTokenIs(token.STRING_MIDDLE):
+			p.nextToken()
+			expression.Strings = append(expression.Strings, p.curToken.Literal)
+		default:
+			p.peekError(token.RBRACE)
+			return nil
+		}
+	}
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{Operator: p.curToken.Literal}
+
+	p.nextToken()
+
+	expression.Right = p.parseExpression(PREFIX)
+
+	return expression
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	// Empty tuple
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return &ast.TupleLiteral{Elements: []ast.Expression{}}
+	}
+
+	p.nextToken()
+
+	expression := p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.COMMA) {
+		return p.parseTupleLiteral(expression)
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseTupleLiteral(firstElement ast.Expression) ast.Expression {
+	tuple := &ast.TupleLiteral{Elements: []ast.Expression{firstElement}}
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		// Trailing comma, required by single element tuples
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+
+		p.nextToken()
+		tuple.Elements = append(tuple.Elements, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return tuple
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	function := &ast.FunctionLiteral{}
+
+	function.Parameters, function.ParameterTypes = p.parseFunctionParameters(token.VERTICAL)
+
+	if !p.parseReturnType(function) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	function.Body = p.parseFunctionBody(function)
+
+	return function
+}
+
+func (p *Parser) parseStructStatement() ast.Statement {
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	statement := &ast.StructStatement{Name: &ast.Identifier{Value: p.curToken.Literal}}
+
+	statement.Fields = p.parseNameSet()
+	if statement.Fields == nil {
+		return nil
+	}
+
+	return statement
+}
+
+func (p *Parser) parseTraitStatement() ast.Statement {
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	statement := &ast.TraitStatement{Name: &ast.Identifier{Value: p.curToken.Literal}}
+
+	statement.Methods = p.parseNameSet()
+	if statement.Methods == nil {
+		return nil
+	}
+
+	return statement
+}
+
+// parseNameSet parses the distinct names of struct fields or trait methods
+// in `{ a, b }`, along with an optional semicolon after it.
+func (p *Parser) parseNameSet() []*ast.Identifier {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	names := []*ast.Identifier{}
+	seen := map[string]bool{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		if seen[p.curToken.Literal] {
+			p.errors = append(p.errors, &DuplicateField{Token: p.curToken})
+			return nil
+		}
+		seen[p.curToken.Literal] = true
+		names = append(names, &ast.Identifier{Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return names
+}
+
+func (p *Parser) parseImplStatement() ast.Statement {
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	statement := &ast.ImplStatement{Type: &ast.Identifier{Value: p.curToken.Literal}}
+
+	if p.peekTokenIs(token.FOR) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		statement.Trait = statement.Type
+		statement.Type = &ast.Identifier{Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Methods = []*ast.FunctionLiteral{}
+
+	for {
+		for p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+
+		if !p.expectPeek(token.FN) {
+			return nil
+		}
+
+		method, ok := p.parseFunctionStatement().(*ast.FunctionStatement)
+		if !ok {
+			return nil
+		}
+		statement.Methods = append(statement.Methods, method.Function)
+	}
+
+	p.nextToken()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	function := &ast.FunctionLiteral{Name: p.curToken.Literal}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	function.Parameters, function.ParameterTypes = p.parseFunctionParameters(token.RPAREN)
+	if function.Parameters == nil {
+		return nil
+	}
+
+	if !p.parseReturnType(function) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	function.Body = p.parseFunctionBody(function)
+	if function.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return &ast.FunctionStatement{Function: function}
+}
+
+func (p *Parser) parseFunctionBody(function *ast.FunctionLiteral) *ast.BlockStatement {
+	p.functions = append(p.functions, function)
+	body := p.parseBlockStatement()
+	p.functions = p.functions[:len(p.functions)-1]
+
+	return body
+}
+
+func (p *Parser) parseYieldExpression() ast.Expression {
+	if len(p.functions) == 0 {
+		p.errors = append(p.errors, ILLEGAL_YIELD)
+		return nil
+	}
+
+	// A function is a generator as soon as its own body yields
+	p.functions[len(p.functions)-1].IsGenerator = true
+
+	expression := &ast.YieldExpression{}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if expression.Value == nil {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	expression := &ast.SpreadExpression{}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(PREFIX)
+	if expression.Value == nil {
+		return nil
+	}
+
+	return expression
+}
 
 func (p *Parser) parseMacroLiteral() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
@@ -622,7 +1497,7 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 
 	function := &ast.MacroLiteral{}
 
-	function.Parameters = p.parseFunctionParameters(token.RPAREN)
+	function.Parameters, _ = p.parseFunctionParameters(token.RPAREN)
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -633,30 +1508,74 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 	return function
 }
 
-func (p *Parser) parseFunctionParameters(end token.TokenType) []*ast.Identifier {
+// parseFunctionParameters returns the parameters and their annotations, the
+// latter is nil if no parameter is annotated.
+func (p *Parser) parseFunctionParameters(end token.TokenType) ([]*ast.Identifier, []*ast.Identifier) {
 	identifiers := []*ast.Identifier{}
+	types := []*ast.Identifier{}
+	annotated := false
 	p.nextToken()
 
 	if p.curTokenIs(end) {
-		return identifiers
+		return identifiers, nil
 	}
 
-	identifier := &ast.Identifier{Value: p.curToken.Literal}
-	identifiers = append(identifiers, identifier)
+	for {
+		identifiers = append(identifiers, &ast.Identifier{Value: p.curToken.Literal})
+
+		var annotation *ast.Identifier
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			if annotation = p.parseTypeAnnotation(); annotation == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, annotation)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 
-	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-
-		identifier := &ast.Identifier{Value: p.curToken.Literal}
-		identifiers = append(identifiers, identifier)
 	}
 
 	if !p.expectPeek(end) {
+		return nil, nil
+	}
+
+	if !annotated {
+		types = nil
+	}
+
+	return identifiers, types
+}
+
+// parseTypeAnnotation parses the type name following a colon or an arrow.
+func (p *Parser) parseTypeAnnotation() *ast.Identifier {
+	// `fn` is the type of all functions
+	if p.peekTokenIs(token.FN) {
+		p.nextToken()
+		return &ast.Identifier{Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IDENTIFIER) {
 		return nil
 	}
 
-	return identifiers
+	return &ast.Identifier{Value: p.curToken.Literal}
+}
+
+// parseReturnType parses an optional `-> type`, it returns false on errors.
+func (p *Parser) parseReturnType(function *ast.FunctionLiteral) bool {
+	if !p.peekTokenIs(token.ARROW) {
+		return true
+	}
+
+	p.nextToken()
+	function.ReturnType = p.parseTypeAnnotation()
+	return function.ReturnType != nil
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -698,11 +1617,52 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	return array
 }
 
-func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Left: left}
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
 
+	return &ast.MemberExpression{Object: object, Member: &ast.Identifier{Value: p.curToken.Literal}}
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+
+	if p.curTokenIs(token.COLON) {
+		return p.parseSliceExpression(left, nil)
+	}
+
+	index := p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{Left: left, Index: index}
+}
+
+func (p *Parser) parseSliceExpression(left ast.Expression, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Left: left, Start: start}
+
+	// p.curToken is the first colon
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(LOWEST)
+		}
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -727,12 +1687,19 @@ func NewParser() *Parser {
 	parser.registerPrefix(token.INT, parser.parseIntegerLiteral)
 	parser.registerPrefix(token.MINUS, parser.parsePrefixExpression)
 	parser.registerPrefix(token.EXCLAMATION, parser.parsePrefixExpression)
+	parser.registerPrefix(token.TILDE, parser.parsePrefixExpression)
 	parser.registerPrefix(token.TRUE, parser.parseBoolean)
 	parser.registerPrefix(token.FALSE, parser.parseBoolean)
 	parser.registerPrefix(token.LPAREN, parser.parseGroupedExpression)
 	parser.registerPrefix(token.IF, parser.parseIfExpression)
+	parser.registerPrefix(token.MATCH, parser.parseMatchExpression)
+	parser.registerPrefix(token.YIELD, parser.parseYieldExpression)
+	parser.registerPrefix(token.SPAWN, parser.parseSpawnExpression)
+	parser.registerPrefix(token.SELECT, parser.parseSelectExpression)
+	parser.registerPrefix(token.ELLIPSIS, parser.parseSpreadExpression)
 	parser.registerPrefix(token.VERTICAL, parser.parseFunctionLiteral)
 	parser.registerPrefix(token.STRING, parser.parseStringLiteral)
+	parser.registerPrefix(token.STRING_HEAD, parser.parseInterpolatedString)
 	parser.registerPrefix(token.LBRACKET, parser.parseArrayLiteral)
 	parser.registerPrefix(token.LBRACE, parser.parseMapLiteral)
 	parser.registerPrefix(token.MACRO, parser.parseMacroLiteral)
@@ -750,8 +1717,14 @@ func NewParser() *Parser {
 	parser.registerInfix(token.GTE, parser.parseInfixExpression)
 	parser.registerInfix(token.EXP, parser.parseInfixExpression)
 	parser.registerInfix(token.PERCENT, parser.parseInfixExpression)
+	parser.registerInfix(token.AMPERSAND, parser.parseInfixExpression)
+	parser.registerInfix(token.CARET, parser.parseInfixExpression)
+	parser.registerInfix(token.BOR, parser.parseInfixExpression)
+	parser.registerInfix(token.LSHIFT, parser.parseInfixExpression)
+	parser.registerInfix(token.RSHIFT, parser.parseInfixExpression)
 	parser.registerInfix(token.LPAREN, parser.parseCallExpression)
 	parser.registerInfix(token.LBRACKET, parser.parseIndexExpression)
+	parser.registerInfix(token.DOT, parser.parseMemberExpression)
 
 	return parser
 }
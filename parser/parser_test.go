@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/vita-dounai/Firework/ast"
@@ -241,6 +243,63 @@ func TestIntegerLiteral(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteral(t *testing.T) {
+	input := "18446744073709551616;"
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("exp is not *ast.BigIntegerLiteral, got=%T", stmt.Expression)
+	}
+
+	if literal.String() != "18446744073709551616" {
+		t.Errorf("literal.String() wrong, got=%q", literal.String())
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0x1F", 31},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"0b1011", 11},
+		{"1_000_000", 1000000},
+		{"0xFF_FF", 65535},
+		{"0b1111_0000", 240},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		checkIntegerLiteral(t, stmt.Expression, tt.expected)
+	}
+
+	for _, input := range []string{"0x", "1__0", "0b102", "12abc", "1_"} {
+		l := lexer.NewLexer(input)
+		p := NewParser()
+		p.Init(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) != 1 || p.Errors()[0].Type() != ILLEGAL_INTEGER_ERROR {
+			t.Errorf("expected ILLEGAL_INTEGER error for %q, got=%v", input, p.Errors())
+		}
+	}
+}
+
 func TestBoolean(t *testing.T) {
 	input := `
 	true;
@@ -295,6 +354,46 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	input := `"Hello ${name}, you have ${len(items) + 1} items"`
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	interpolated, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp is not *ast.InterpolatedString, got=%T", stmt.Expression)
+	}
+
+	expectedStrings := []string{"Hello ", ", you have ", " items"}
+	if len(interpolated.Strings) != len(expectedStrings) {
+		t.Fatalf("interpolated.Strings has wrong length, got=%d", len(interpolated.Strings))
+	}
+
+	for i, str := range expectedStrings {
+		if interpolated.Strings[i] != str {
+			t.Errorf("interpolated.Strings[%d] is not %q, got=%q", i, str, interpolated.Strings[i])
+		}
+	}
+
+	if len(interpolated.Expressions) != 2 {
+		t.Fatalf("interpolated.Expressions has wrong length, got=%d", len(interpolated.Expressions))
+	}
+
+	checkIdentifier(t, interpolated.Expressions[0], "name")
+	if interpolated.Expressions[1].String() != "(len(items) + 1)" {
+		t.Errorf("wrong second expression, got=%q", interpolated.Expressions[1].String())
+	}
+
+	if interpolated.String() != `"Hello ${name}, you have ${(len(items) + 1)} items"` {
+		t.Errorf("interpolated.String() wrong, got=%q", interpolated.String())
+	}
+}
+
 func TestPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -494,6 +593,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])));",
 		},
+		{
+			"a bor b ^ c & d",
+			"(a bor (b ^ (c & d)));",
+		},
+		{
+			"a & b << 1 + c",
+			"(a & (b << (1 + c)));",
+		},
+		{
+			"a >> 1 == b & 1",
+			"((a >> 1) == (b & 1));",
+		},
+		{
+			"~a & -b",
+			"((~a) & (-b));",
+		},
+		{
+			"a < b bor c",
+			"(a < (b bor c));",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.NewLexer(tt.input)
@@ -797,6 +916,32 @@ func TestParsingArrayLiterals(t *testing.T) {
 	checkInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingTupleLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1, 2 * 2, 3 + 3)", "(1, (2 * 2), (3 + 3))"},
+		{"(1,)", "(1,)"},
+		{"()", "()"},
+		{"(1, 2,)", "(1, 2)"},
+		{"(1)", "1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.Expression.String())
+		}
+	}
+}
+
 func TestParsingArrayIndexExpressions(t *testing.T) {
 	input := "myArray[1 + 1]"
 	l := lexer.NewLexer(input)
@@ -820,6 +965,40 @@ func TestParsingArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::-1]", "(a[::(-1)])"},
+		{"a[1 + 1:len(a):2]", "(a[(1 + 1):len(a):2])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.IndexExpression); !ok {
+			if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+				t.Fatalf("exp is not *ast.SliceExpression, got=%T", stmt.Expression)
+			}
+		}
+
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.Expression.String())
+		}
+	}
+}
+
 func TestParsingMapIndexExpressions(t *testing.T) {
 	input := `{2: "2"}[1 + 1]`
 	l := lexer.NewLexer(input)
@@ -874,7 +1053,8 @@ func TestParsingMapLiteralsStringKeys(t *testing.T) {
 		"three": 3,
 	}
 
-	for key, value := range mapLiteral.Pairs {
+	for _, pair := range mapLiteral.Pairs {
+		key, value := pair.Key, pair.Value
 		keyLiteral, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral, got=%T", keyLiteral)
@@ -885,6 +1065,40 @@ func TestParsingMapLiteralsStringKeys(t *testing.T) {
 	}
 }
 
+func TestParsingMapLiteralsKeyOrder(t *testing.T) {
+	input := `{"c": 1, "a": 2, "b": 3, "a": 4}`
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	mapLiteral, ok := stmt.Expression.(*ast.MapLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.MapLiteral, got=%T", stmt.Expression)
+	}
+
+	expectedKeys := []string{"c", "a", "b", "a"}
+	if len(mapLiteral.Pairs) != len(expectedKeys) {
+		t.Fatalf("mapLiteral.Pairs has wrong length, got=%d", len(mapLiteral.Pairs))
+	}
+
+	for i, pair := range mapLiteral.Pairs {
+		keyLiteral, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("key is not ast.StringLiteral, got=%T", pair.Key)
+		}
+
+		if keyLiteral.PureString() != expectedKeys[i] {
+			t.Errorf("key %d is not %q, got=%q", i, expectedKeys[i], keyLiteral.PureString())
+		}
+
+		checkIntegerLiteral(t, pair.Value, int64(i+1))
+	}
+}
+
 func TestParsingMapLiteralsBooleanKeys(t *testing.T) {
 	input := `{true: 1, false: 0}`
 	l := lexer.NewLexer(input)
@@ -908,7 +1122,8 @@ func TestParsingMapLiteralsBooleanKeys(t *testing.T) {
 		false: 0,
 	}
 
-	for key, value := range mapLiteral.Pairs {
+	for _, pair := range mapLiteral.Pairs {
+		key, value := pair.Key, pair.Value
 		keyLiteral, ok := key.(*ast.Boolean)
 		if !ok {
 			t.Errorf("key is not ast.Boolean, got=%T", keyLiteral)
@@ -942,7 +1157,8 @@ func TestParsingMapLiteralsIntegerKeys(t *testing.T) {
 		0: 0,
 	}
 
-	for key, value := range mapLiteral.Pairs {
+	for _, pair := range mapLiteral.Pairs {
+		key, value := pair.Key, pair.Value
 		keyLiteral, ok := key.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("key is not ast.Boolean, got=%T", keyLiteral)
@@ -1039,7 +1255,8 @@ func TestParsingMapLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range mapLiteral.Pairs {
+	for _, pair := range mapLiteral.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral, got=%T", key)
@@ -1143,7 +1360,8 @@ func TestParsingMapLiteralsInBlockStatement(t *testing.T) {
 		"three": 3,
 	}
 
-	for key, value := range mapLiteral.Pairs {
+	for _, pair := range mapLiteral.Pairs {
+		key, value := pair.Key, pair.Value
 		keyLiteral, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not in map literal, got=%T", key)
@@ -1197,3 +1415,602 @@ func TestMacroLiteralParsing(t *testing.T) {
 
 	checkInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType string
+		expectedInfo string
+	}{
+		{
+			"x = \"abc",
+			UNTERMINATED_STRING_ERROR,
+			"string literal not terminated, line: 1, column: 5",
+		},
+		{
+			"print(\"abc\\q\")",
+			ILLEGAL_ESCAPE_ERROR,
+			"unknown escape sequence `\\q`, line: 1, column: 11",
+		},
+		{
+			"x = 1 + @",
+			ILLEGAL_SYMBOL_ERROR,
+			"symbol not recognized `@`, line: 1, column: 9",
+		},
+		{
+			"f(1 $)",
+			ILLEGAL_SYMBOL_ERROR,
+			"symbol not recognized `$`, line: 1, column: 5",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("no parse error for %q", tt.input)
+		}
+
+		err := p.Errors()[0]
+		if err.Type() != tt.expectedType {
+			t.Errorf("wrong error type for %q, expected=%q, got=%q", tt.input, tt.expectedType, err.Type())
+		}
+
+		if err.Info() != tt.expectedInfo {
+			t.Errorf("wrong error info for %q, expected=%q, got=%q", tt.input, tt.expectedInfo, err.Info())
+		}
+	}
+}
+
+func TestElseIfExpression(t *testing.T) {
+	input := "if (x < 0) { -1 } else if (x == 0) { 0 } else { 1 }"
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement, got=%d", len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression, got=%T", stmt.Expression)
+	}
+
+	elseIf := exp.ElseIf()
+	if elseIf == nil {
+		t.Fatalf("exp.Alternative is not an else if, got=%v", exp.Alternative)
+	}
+
+	if !checkInfixExpression(t, elseIf.Condition, "x", "==", 0) {
+		return
+	}
+
+	if elseIf.Alternative == nil || len(elseIf.Alternative.Statements) != 1 {
+		t.Fatalf("elseIf.Alternative is not a single statement block, got=%v", elseIf.Alternative)
+	}
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	input := `match x {
+		0 => "zero",
+		1..10 => "small",
+		10..=20 => "medium",
+		[first, ..rest] => first,
+		[] => "empty",
+		{"k": v} if v > 1 => { v },
+		n => n
+	}`
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement, got=%d", len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression, got=%T", stmt.Expression)
+	}
+
+	if !checkIdentifier(t, exp.Subject, "x") {
+		return
+	}
+
+	expectedPatterns := []string{
+		"*ast.LiteralPattern",
+		"*ast.RangePattern",
+		"*ast.RangePattern",
+		"*ast.ArrayPattern",
+		"*ast.ArrayPattern",
+		"*ast.MapPattern",
+		"*ast.BindingPattern",
+	}
+
+	if len(exp.Arms) != len(expectedPatterns) {
+		t.Fatalf("exp.Arms does not contain %d arms, got=%d", len(expectedPatterns), len(exp.Arms))
+	}
+
+	for i, arm := range exp.Arms {
+		if got := fmt.Sprintf("%T", arm.Pattern); got != expectedPatterns[i] {
+			t.Errorf("arm %d has wrong pattern type, expected=%s, got=%s", i, expectedPatterns[i], got)
+		}
+	}
+
+	if exp.Arms[1].Pattern.(*ast.RangePattern).Inclusive {
+		t.Errorf("1..10 should be an exclusive range")
+	}
+
+	if !exp.Arms[2].Pattern.(*ast.RangePattern).Inclusive {
+		t.Errorf("10..=20 should be an inclusive range")
+	}
+
+	rest := exp.Arms[3].Pattern.(*ast.ArrayPattern)
+	if !rest.HasRest || rest.Rest == nil || rest.Rest.Value != "rest" {
+		t.Errorf("[first, ..rest] has wrong rest binding, got=%s", rest.String())
+	}
+
+	if exp.Arms[5].Guard == nil {
+		t.Fatalf("arm 5 has no guard")
+	}
+
+	if !checkInfixExpression(t, exp.Arms[5].Guard, "v", ">", 1) {
+		return
+	}
+
+	if _, ok := exp.Arms[5].Body.(*ast.BlockStatement); !ok {
+		t.Errorf("arm 5 body is not ast.BlockStatement, got=%T", exp.Arms[5].Body)
+	}
+}
+
+func TestIllegalPattern(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType string
+		expectedInfo string
+	}{
+		{"match x { [1, (a)] => 1 }", ILLEGAL_PATTERN_ERROR, "`(` is not a valid pattern, line: 1, column: 15"},
+		{"match x { |a| => 1 }", ILLEGAL_PATTERN_ERROR, "`|` is not a valid pattern, line: 1, column: 11"},
+		{"match x { [a, a] => 1 }", DUPLICATE_BINDING_ERROR, "`a` is bound twice by the pattern, line: 1, column: 15"},
+		{`match x { {"a": a, "b": [_, ..a]} => 1 }`, DUPLICATE_BINDING_ERROR, "`a` is bound twice by the pattern, line: 1, column: 31"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("no parse error for %q", tt.input)
+		}
+
+		err := p.Errors()[0]
+		if err.Type() != tt.expectedType {
+			t.Errorf("wrong error type for %q, expected=%q, got=%q", tt.input, tt.expectedType, err.Type())
+		}
+
+		if err.Info() != tt.expectedInfo {
+			t.Errorf("wrong error info for %q, expected=%q, got=%q", tt.input, tt.expectedInfo, err.Info())
+		}
+	}
+}
+
+func TestFunctionStatementParsing(t *testing.T) {
+	input := "fn add(x, y) { x + y; }"
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement, got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionStatement, got=%T", program.Statements[0])
+	}
+
+	function := stmt.Function
+	if function.Name != "add" {
+		t.Errorf("function.Name is not 'add', got=%q", function.Name)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong, want 2, got=%d", len(function.Parameters))
+	}
+
+	checkLiteralExpression(t, function.Parameters[0], "x")
+	checkLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statement, got=%d", len(function.Body.Statements))
+	}
+
+	expected := "fn add(x, y) {\n    (x + y);\n}"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong, expected=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestForStatementParsing(t *testing.T) {
+	input := "for x in xs { print(x); }"
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement, got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement, got=%T", program.Statements[0])
+	}
+
+	if !checkIdentifier(t, stmt.Variable, "x") || !checkIdentifier(t, stmt.Iterable, "xs") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("stmt.Body.Statements has not 1 statement, got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestGeneratorParsing(t *testing.T) {
+	input := "fn gen(xs) { f = |x| { x * 2 }; for x in xs { yield f(x); } }; g = |x| { yield x; }; h = |x| { x }"
+
+	l := lexer.NewLexer(input)
+	p := NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements, got=%d", len(program.Statements))
+	}
+
+	gen := program.Statements[0].(*ast.FunctionStatement).Function
+	if !gen.IsGenerator {
+		t.Errorf("gen is not a generator")
+	}
+
+	inner := gen.Body.Statements[0].(*ast.AssignStatement).Value.(*ast.FunctionLiteral)
+	if inner.IsGenerator {
+		t.Errorf("nested function without yield is a generator")
+	}
+
+	g := program.Statements[1].(*ast.AssignStatement).Value.(*ast.FunctionLiteral)
+	if !g.IsGenerator {
+		t.Errorf("g is not a generator")
+	}
+
+	h := program.Statements[2].(*ast.AssignStatement).Value.(*ast.FunctionLiteral)
+	if h.IsGenerator {
+		t.Errorf("h is a generator")
+	}
+
+	l = lexer.NewLexer("yield 1")
+	p.Init(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 || p.Errors()[0].Type() != ILLEGAL_YIELD_ERROR {
+		t.Errorf("expected %s error for yield outside function, got=%v", ILLEGAL_YIELD_ERROR, p.Errors())
+	}
+}
+
+func TestSpreadExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, ...xs, 2]", "[1, ...xs, 2];"},
+		{"f(...xs, ...g(y))", "f(...xs, ...g(y));"},
+		{"[...xs[1:]]", "[...(xs[1:])];"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestConcurrencyParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"spawn f(1, x)", "(spawn f(1, x));"},
+		{"r = recv(spawn f())", "r = recv((spawn f()));"},
+		{"select { x = recv(a) => x, send(b, 1) => { 2 }, _ => 3 }",
+			"select { x = recv(a) => x, send(b, 1) => {\n    2;\n}, _ => 3 }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input        string
+		expectedType string
+	}{
+		{"spawn x", ILLEGAL_SPAWN_ERROR},
+		{"select { f(a) => 1 }", ILLEGAL_SELECT_CASE_ERROR},
+		{"select { x = send(a, 1) => 1 }", ILLEGAL_SELECT_CASE_ERROR},
+		{"select { recv(a, b) => 1 }", ILLEGAL_SELECT_CASE_ERROR},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0].Type() != tt.expectedType {
+			t.Errorf("expected %s error for %q, got=%v", tt.expectedType, tt.input, p.Errors())
+		}
+	}
+}
+
+func TestModuleParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/util"`, `import "lib/util";`},
+		{`from "util" import a, b; a`, `from "util" import a, b;a;`},
+		{"export x = 1", "export x = 1;"},
+		{"export fn f(a) { a }", "export fn f(a) {\n    a;\n}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []string{
+		"export 1",
+		"fn f() { export x = 1 }",
+		"while true { export fn g() {} }",
+	}
+
+	for _, input := range errorTests {
+		l := lexer.NewLexer(input)
+		p := NewParser()
+		p.Init(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0].Type() != ILLEGAL_EXPORT_ERROR {
+			t.Errorf("expected %s error for %q, got=%v", ILLEGAL_EXPORT_ERROR, input, p.Errors())
+		}
+	}
+}
+
+func TestStructParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }", "struct Point { x, y }"},
+		{"struct Unit {}", "struct Unit {}"},
+		{"export struct Pair { first, second, }", "export struct Pair { first, second }"},
+		{"p.x", "(p.x);"},
+		{"a.b.c + f(x).y", "(((a.b).c) + (f(x).y));"},
+		{"p.x = p.y + 1", "(p.x) = ((p.y) + 1);"},
+		{`"a".upper().split(",")`, `(("a".upper)().split)(",");`},
+		{"{ p.x = 1 }", "{\n    (p.x) = 1;\n}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	l := lexer.NewLexer("struct Point { x, y, x }")
+	p := NewParser()
+	p.Init(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 || p.Errors()[0].Type() != DUPLICATE_FIELD_ERROR {
+		t.Fatalf("expected %s error, got=%v", DUPLICATE_FIELD_ERROR, p.Errors())
+	}
+
+	expectedInfo := "duplicate name `x`, line: 1, column: 22"
+	if p.Errors()[0].Info() != expectedInfo {
+		t.Errorf("wrong error info, expected=%q, got=%q", expectedInfo, p.Errors()[0].Info())
+	}
+}
+
+func TestImplParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"trait Shape { area, perimeter }", "trait Shape { area, perimeter }"},
+		{"export trait Empty {}", "export trait Empty {}"},
+		{"impl Point {}", "impl Point {}"},
+		{"impl Point { fn norm(self) { self.x } }", "impl Point { fn norm(self) {\n    (self.x);\n} }"},
+		{"impl Shape for Square {\n fn area(self) { 1 };\n fn name(self) { 2 }\n}",
+			"impl Shape for Square { fn area(self) {\n    1;\n} fn name(self) {\n    2;\n} }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input        string
+		expectedType string
+	}{
+		{"impl Point { x = 1 }", ILLEGAL_SYNTAX_ERROR},
+		{"impl Shape for { }", ILLEGAL_SYNTAX_ERROR},
+		{"trait Shape { area, area }", DUPLICATE_FIELD_ERROR},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0].Type() != tt.expectedType {
+			t.Errorf("expected %s error for %q, got=%v", tt.expectedType, tt.input, p.Errors())
+		}
+	}
+}
+
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"|a: int, b| -> str { b }", "|a: int, b| -> str {\n    b;\n};"},
+		{"fn add(a: int, b: int) -> int { a + b }", "fn add(a: int, b: int) -> int {\n    (a + b);\n}"},
+		{"fn apply(f: fn, x) { f(x) }", "fn apply(f: fn, x) {\n    f(x);\n}"},
+		{"x: int = 5", "x: int = 5;"},
+		{"if true { y: str = \"a\" }", "if true {\n    y: str = \"a\";\n}"},
+		{"export z: bool = true", "export z: bool = true;"},
+		{"fn id(x) { x }", "fn id(x) {\n    x;\n}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input        string
+		expectedType string
+	}{
+		{"|a: | { a }", ILLEGAL_SYNTAX_ERROR},
+		{"fn f() -> { 1 }", ILLEGAL_SYNTAX_ERROR},
+		{"x: int 5", ILLEGAL_SYNTAX_ERROR},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0].Type() != tt.expectedType {
+			t.Errorf("expected %s error for %q, got=%v", tt.expectedType, tt.input, p.Errors())
+		}
+	}
+}
+
+// statementPositions lists the positions of the statements in program and in
+// the blocks they hold, as line:column.
+func statementPositions(statements []ast.Statement) []string {
+	positions := []string{}
+
+	for _, statement := range statements {
+		positions = append(positions, fmt.Sprintf("%d:%d", statement.Pos().Line, statement.Pos().Column))
+
+		switch statement := statement.(type) {
+		case *ast.BlockStatement:
+			positions = append(positions, statementPositions(statement.Statements)...)
+		case *ast.FunctionStatement:
+			positions = append(positions, statementPositions(statement.Function.Body.Statements)...)
+		case *ast.WhileStatement:
+			positions = append(positions, statementPositions(statement.Body.Statements)...)
+		case *ast.ExpressionStatement:
+			if ie, ok := statement.Expression.(*ast.IfExpression); ok {
+				positions = append(positions, statementPositions(ie.Consequence.Statements)...)
+				if ie.Alternative != nil {
+					positions = append(positions, statementPositions(ie.Alternative.Statements)...)
+				}
+			}
+		}
+	}
+
+	return positions
+}
+
+func TestStatementPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x = 1\ny = 2; z = 3", []string{"1:1", "2:1", "2:8"}},
+		{"fn f(n) {\n    return n\n}", []string{"1:1", "2:5"}},
+		{"while true {\n  break\n}", []string{"1:1", "2:3"}},
+		{"if a {\n b\n} else if c {\n d\n}", []string{"1:1", "2:2", "3:8", "4:2"}},
+		{"{ p.x = 1\n  q }", []string{"1:1", "1:3", "2:3"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser()
+		p.Init(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		positions := statementPositions(program.Statements)
+		if strings.Join(positions, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("wrong positions for %q, expected=%v, got=%v", tt.input, tt.expected, positions)
+		}
+	}
+}
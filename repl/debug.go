@@ -0,0 +1,258 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vita-dounai/Firework/debugger"
+	"github.com/vita-dounai/Firework/evaluator"
+	"github.com/vita-dounai/Firework/object"
+)
+
+const DEBUG_PROMPT = "(debug) "
+
+const DEBUG_HELP = `Commands while paused:
+  s, step              run to the next line, entering calls
+  n, next              run to the next line, over calls
+  o, out               run until the current function returns
+  c, continue          run until the next breakpoint
+  b, break [LOC]       add a breakpoint at LINE, FILE:LINE or a function, list them without LOC
+  d, delete LOC        remove a breakpoint
+  bt, stack            print the frames being evaluated
+  e, env               print the environment chain of the current frame
+  p, print EXPR        evaluate EXPR in the current frame
+  h, help              print this help
+`
+
+// debugSession reads the commands of a paused debugger from the REPL input.
+type debugSession struct {
+	debugger *debugger.Debugger
+	scanner  *bufio.Scanner
+	out      io.Writer
+	// Lines of the sources being debugged, by path
+	sources map[string][]string
+	// Breakpoints given as a bare line are in this file
+	path string
+}
+
+func newDebugSession(scanner *bufio.Scanner, out io.Writer) *debugSession {
+	s := &debugSession{
+		debugger: debugger.NewDebugger(),
+		scanner:  scanner,
+		out:      out,
+		sources:  map[string][]string{},
+	}
+	s.debugger.Paused = s.paused
+	return s
+}
+
+func (s *debugSession) printf(format string, a ...interface{}) {
+	io.WriteString(s.out, fmt.Sprintf(format, a...))
+}
+
+// setSource records the source debugged next, path is empty for REPL input.
+func (s *debugSession) setSource(path string, source string) {
+	s.path = path
+	s.sources[path] = strings.Split(source, "\n")
+}
+
+// sourceLine returns the text at location, reading the files of imported
+// modules as they are reached.
+func (s *debugSession) sourceLine(location debugger.Location) string {
+	lines, ok := s.sources[location.Path]
+	if !ok && location.Path != "" {
+		if source, err := ioutil.ReadFile(location.Path); err == nil {
+			lines = strings.Split(string(source), "\n")
+		}
+		s.sources[location.Path] = lines
+	}
+
+	if location.Line < 1 || location.Line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[location.Line-1])
+}
+
+func (s *debugSession) paused(reason string) {
+	frame := s.debugger.Stack()[0]
+	s.printf("Paused at %s in %s (%s)\n", frame.Location, frame.Name, reason)
+	if line := s.sourceLine(frame.Location); line != "" {
+		s.printf("    %s\n", line)
+	}
+
+	for {
+		fmt.Print(DEBUG_PROMPT)
+		if !s.scanner.Scan() {
+			// Out of input, let the evaluation finish
+			s.debugger.Continue()
+			return
+		}
+
+		fields := strings.Fields(s.scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		argument := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s.scanner.Text()), fields[0]))
+
+		switch fields[0] {
+		case "s", "step":
+			s.debugger.StepInto()
+			return
+		case "n", "next":
+			s.debugger.StepOver()
+			return
+		case "o", "out":
+			s.debugger.StepOut()
+			return
+		case "c", "continue":
+			s.debugger.Continue()
+			return
+		case "b", "break":
+			s.addBreakpoint(argument)
+		case "d", "delete":
+			s.removeBreakpoint(argument)
+		case "bt", "stack":
+			s.printStack()
+		case "e", "env":
+			s.printEnvironments(frame.Env)
+		case "p", "print":
+			s.printValue(s.debugger.Evaluate(argument, frame.Env))
+		case "h", "help":
+			io.WriteString(s.out, DEBUG_HELP)
+		default:
+			s.printf("Unknown command: %s\n", fields[0])
+		}
+	}
+}
+
+// debugFile runs the module at path under the debugger, paused at its first
+// line.
+func debugFile(s *debugSession, path string, out io.Writer) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		io.WriteString(out, err.Error()+"\n")
+		return
+	}
+
+	source, err := ioutil.ReadFile(absolute)
+	if err != nil {
+		io.WriteString(out, err.Error()+"\n")
+		return
+	}
+
+	s.setSource(absolute, string(source))
+	s.debugger.Start(true)
+	evaluator.SetHook(s.debugger)
+	defer evaluator.SetHook(nil)
+
+	if _, evalErr := evaluator.RunModule(absolute); evalErr != nil {
+		io.WriteString(out, evalErr.Inspect()+"\n")
+	}
+}
+
+// location parses LINE or FILE:LINE, ok is false for a function name.
+func (s *debugSession) location(argument string) (debugger.Location, bool) {
+	path, line := s.path, argument
+	if i := strings.LastIndex(argument, ":"); i >= 0 {
+		path, line = argument[:i], argument[i+1:]
+		if absolute, err := filepath.Abs(path); err == nil {
+			path = absolute
+		}
+	}
+
+	number, err := strconv.Atoi(line)
+	if err != nil {
+		return debugger.Location{}, false
+	}
+	return debugger.Location{Path: path, Line: number}, true
+}
+
+func (s *debugSession) addBreakpoint(argument string) {
+	if argument == "" {
+		s.printBreakpoints()
+		return
+	}
+
+	if location, ok := s.location(argument); ok {
+		s.debugger.AddBreakpoint(location)
+		s.printf("Breakpoint at %s\n", location)
+		return
+	}
+
+	s.debugger.AddFunctionBreakpoint(argument)
+	s.printf("Breakpoint on %s\n", argument)
+}
+
+func (s *debugSession) removeBreakpoint(argument string) {
+	removed := false
+	if location, ok := s.location(argument); ok {
+		removed = s.debugger.RemoveBreakpoint(location)
+	} else {
+		removed = s.debugger.RemoveFunctionBreakpoint(argument)
+	}
+
+	if !removed {
+		s.printf("No breakpoint at %s\n", argument)
+	}
+}
+
+func (s *debugSession) printBreakpoints() {
+	locations, names := s.debugger.Breakpoints()
+
+	breakpoints := []string{}
+	for _, location := range locations {
+		breakpoints = append(breakpoints, location.String())
+	}
+	breakpoints = append(breakpoints, names...)
+
+	if len(breakpoints) == 0 {
+		io.WriteString(s.out, "No breakpoints\n")
+		return
+	}
+	sort.Strings(breakpoints)
+
+	for _, breakpoint := range breakpoints {
+		s.printf("%s\n", breakpoint)
+	}
+}
+
+func (s *debugSession) printStack() {
+	for i, frame := range s.debugger.Stack() {
+		s.printf("#%d %s at %s\n", i, frame.Name, frame.Location)
+	}
+}
+
+// printEnvironments prints the variables of env and of every environment it
+// extends, the globals last.
+func (s *debugSession) printEnvironments(env *object.Environment) {
+	for depth := 0; env != nil; depth++ {
+		if env.Outer() == nil {
+			io.WriteString(s.out, "globals:\n")
+		} else {
+			s.printf("frame %d:\n", depth)
+		}
+
+		for _, binding := range env.Bindings() {
+			if _, ok := binding.Value.(*object.Module); ok && binding.Name == "import" {
+				// The module being evaluated
+				continue
+			}
+			s.printf("    %s = %s\n", binding.Name, s.debugger.Inspect(binding.Value))
+		}
+
+		env = env.Outer()
+	}
+}
+
+func (s *debugSession) printValue(value object.Object) {
+	if value != nil {
+		io.WriteString(s.out, s.debugger.Inspect(value)+"\n")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/vita-dounai/Firework/ast"
 	"github.com/vita-dounai/Firework/evaluator"
 	"github.com/vita-dounai/Firework/lexer"
 	"github.com/vita-dounai/Firework/object"
@@ -15,13 +16,22 @@ import (
 const PROMPT = ">> "
 const CONTINUE_PROMPT = ".."
 
+// checkInputNotEnd reports whether all parse errors could be fixed by more
+// input, such as an open block or an unterminated string.
 func checkInputNotEnd(p *parser.Parser) bool {
-	if len(p.Errors()) == 1 {
-		if p.Errors()[0] == parser.UNEXPECTED_EOF {
-			return true
+	if len(p.Errors()) == 0 {
+		return false
+	}
+
+	for _, err := range p.Errors() {
+		switch err.Type() {
+		case parser.UNEXPECTED_EOF_ERROR, parser.UNTERMINATED_STRING_ERROR:
+		default:
+			return false
 		}
 	}
-	return false
+
+	return true
 }
 
 func Start(in io.Reader, out io.Writer) {
@@ -30,8 +40,11 @@ func Start(in io.Reader, out io.Writer) {
 	macroEnv := object.NewEnvironment()
 	p := parser.NewParser()
 
+	// Set while input is evaluated under the debugger
+	var debug *debugSession
+
 	for {
-		fmt.Printf(PROMPT)
+		fmt.Print(PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -39,13 +52,27 @@ func Start(in io.Reader, out io.Writer) {
 
 		line := scanner.Text()
 		if strings.HasPrefix(line, ".") {
-			command := line[1:]
+			command := strings.Fields(line[1:])
+			if len(command) == 0 {
+				command = []string{""}
+			}
 
-			switch command {
+			switch command[0] {
 			case "exit":
 				return
+			case "debug":
+				if len(command) > 1 {
+					debugFile(newDebugSession(scanner, out), command[1], out)
+				} else if debug == nil {
+					debug = newDebugSession(scanner, out)
+					io.WriteString(out, "Debugging on, input pauses at its first line\n")
+				} else {
+					debug = nil
+					io.WriteString(out, "Debugging off\n")
+				}
+				continue
 			default:
-				io.WriteString(out, fmt.Sprintf("Unknown command: %s\n", command))
+				io.WriteString(out, fmt.Sprintf("Unknown command: %s\n", command[0]))
 				continue
 			}
 		}
@@ -56,7 +83,7 @@ func Start(in io.Reader, out io.Writer) {
 
 		for checkInputNotEnd(p) {
 			ident := p.Ident()
-			fmt.Printf(CONTINUE_PROMPT + strings.Repeat(".", ident*2) + " ")
+			fmt.Print(CONTINUE_PROMPT + strings.Repeat(".", ident*2) + " ")
 
 			scanned := scanner.Scan()
 			if !scanned {
@@ -77,9 +104,21 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+		expanded := evaluator.ExpandMacros(program, macroEnv).(*ast.Program)
+
+		if err := evaluator.ResolveInput(expanded, env); err != nil {
+			io.WriteString(out, err.Inspect()+"\n")
+			continue
+		}
+
+		if debug != nil {
+			debug.setSource("", line)
+			debug.debugger.Start(true)
+			evaluator.SetHook(debug.debugger)
+		}
 
 		evaluated := evaluator.Eval(expanded, env)
+		evaluator.SetHook(nil)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
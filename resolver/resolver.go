@@ -0,0 +1,450 @@
+package resolver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vita-dounai/Firework/ast"
+)
+
+// scope mirrors a frame created by the evaluator, or the global environment.
+type scope struct {
+	global  bool
+	names   []string
+	pending []*pendingFunction
+}
+
+// pendingFunction is resolved when the scope it is declared in ends, so that
+// its body sees the variables assigned after it.
+type pendingFunction struct {
+	literal *ast.FunctionLiteral
+	scopes  []*scope
+}
+
+// Resolver binds the local variables of a macro expanded program to slots in
+// the frames of the evaluator. Globals are left to be looked up by name, like
+// identifiers in code the resolver never sees, such as quoted code.
+type Resolver struct {
+	// Set for input which later input may extend, as in the REPL, unknown
+	// names read in functions are then taken to be globals defined later
+	Interactive bool
+
+	errors    []string
+	scopes    []*scope
+	functions int // Depth of function bodies being resolved
+	// Names assigned at the top level of the program
+	globals map[string]bool
+	// Reports names defined before the program is run, such as builtins
+	defined func(name string) bool
+	// Identifiers found in several places after macro expansion are looked
+	// up by name
+	slots map[*ast.Identifier]ast.Slot
+}
+
+func NewResolver(defined func(name string) bool) *Resolver {
+	return &Resolver{
+		globals: map[string]bool{},
+		defined: defined,
+		slots:   map[*ast.Identifier]ast.Slot{},
+	}
+}
+
+func (r *Resolver) Errors() []string {
+	return r.errors
+}
+
+func (r *Resolver) errorf(format string, a ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, a...))
+}
+
+func (r *Resolver) Resolve(program *ast.Program) {
+	for _, statement := range program.Statements {
+		for _, name := range declaredNames(statement) {
+			r.globals[name] = true
+		}
+	}
+
+	global := &scope{global: true}
+	r.scopes = []*scope{global}
+	r.resolveStatements(program.Statements)
+	r.endScope(global)
+}
+
+func unwrapExport(statement ast.Statement) ast.Statement {
+	if export, ok := statement.(*ast.ExportStatement); ok {
+		return export.Statement
+	}
+	return statement
+}
+
+// declaredNames are the names a statement binds in its scope.
+func declaredNames(statement ast.Statement) []string {
+	switch statement := unwrapExport(statement).(type) {
+	case *ast.AssignStatement:
+		return []string{statement.Name.Value}
+	case *ast.FunctionStatement:
+		return []string{statement.Function.Name}
+	case *ast.StructStatement:
+		return []string{statement.Name.Value}
+	case *ast.TraitStatement:
+		return []string{statement.Name.Value}
+	case *ast.ImportStatement:
+		if statement.Names == nil {
+			base := filepath.Base(statement.Path)
+			return []string{strings.TrimSuffix(base, filepath.Ext(base))}
+		}
+
+		names := []string{}
+		for _, name := range statement.Names {
+			names = append(names, name.Value)
+		}
+		return names
+	default:
+		return nil
+	}
+}
+
+func (r *Resolver) current() *scope {
+	return r.scopes[len(r.scopes)-1]
+}
+
+// beginScope pushes the scope of a new frame.
+func (r *Resolver) beginScope() *scope {
+	s := &scope{}
+	r.scopes = append(r.scopes, s)
+	return s
+}
+
+// endScope resolves the functions declared in s and pops it.
+func (r *Resolver) endScope(s *scope) {
+	for len(s.pending) > 0 {
+		pending := s.pending[0]
+		s.pending = s.pending[1:]
+		r.resolveFunction(pending.literal, pending.scopes)
+	}
+
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// declare adds a slot for name to the innermost frame unless it has one.
+func (r *Resolver) declare(name string) (ast.Slot, bool) {
+	s := r.current()
+	if s.global {
+		return ast.Slot{}, false
+	}
+
+	for i, n := range s.names {
+		if n == name {
+			return ast.Slot{Index: i}, true
+		}
+	}
+
+	s.names = append(s.names, name)
+	return ast.Slot{Index: len(s.names) - 1}, true
+}
+
+// lookup returns the slot of a local variable.
+func (r *Resolver) lookup(name string) (ast.Slot, bool) {
+	for depth := 0; depth < len(r.scopes); depth++ {
+		s := r.scopes[len(r.scopes)-1-depth]
+		if s.global {
+			break
+		}
+
+		for i, n := range s.names {
+			if n == name {
+				return ast.Slot{Depth: depth, Index: i}, true
+			}
+		}
+	}
+
+	return ast.Slot{}, false
+}
+
+func (r *Resolver) isGlobal(name string) bool {
+	return r.globals[name] || r.defined(name)
+}
+
+func (r *Resolver) bind(identifier *ast.Identifier, slot ast.Slot) {
+	if previous, ok := r.slots[identifier]; ok && previous != slot {
+		identifier.Slot = nil
+		return
+	}
+
+	r.slots[identifier] = slot
+	identifier.Slot = &slot
+}
+
+func (r *Resolver) resolveRead(identifier *ast.Identifier) {
+	if slot, ok := r.lookup(identifier.Value); ok {
+		r.bind(identifier, slot)
+		return
+	}
+
+	if r.isGlobal(identifier.Value) || r.Interactive && r.functions > 0 {
+		return
+	}
+
+	r.errorf("Identifier not found: %s", identifier.Value)
+}
+
+// resolveAssign binds the target of an assignment, which updates a visible
+// variable or else defines one in the innermost frame.
+func (r *Resolver) resolveAssign(identifier *ast.Identifier) {
+	if slot, ok := r.lookup(identifier.Value); ok {
+		r.bind(identifier, slot)
+		return
+	}
+
+	if r.isGlobal(identifier.Value) {
+		return
+	}
+
+	if slot, ok := r.declare(identifier.Value); ok {
+		r.bind(identifier, slot)
+	}
+}
+
+// hoist declares the functions the evaluator defines before running the
+// statements of a scope.
+func (r *Resolver) hoist(statements []ast.Statement) {
+	for _, statement := range statements {
+		if fs, ok := unwrapExport(statement).(*ast.FunctionStatement); ok {
+			r.declare(fs.Function.Name)
+		}
+	}
+}
+
+func (r *Resolver) resolveStatements(statements []ast.Statement) {
+	r.hoist(statements)
+
+	for _, statement := range statements {
+		r.resolveStatement(statement)
+	}
+}
+
+func (r *Resolver) resolveBlock(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+
+	s := r.beginScope()
+	r.resolveStatements(block.Statements)
+	block.Names = s.names
+	r.endScope(s)
+}
+
+func (r *Resolver) resolveStatement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		r.resolveExpression(statement.Expression)
+	case *ast.AssignStatement:
+		r.resolveExpression(statement.Value)
+		r.resolveAssign(statement.Name)
+	case *ast.FieldAssignStatement:
+		r.resolveExpression(statement.Target.Object)
+		r.resolveExpression(statement.Value)
+	case *ast.ReturnStatement:
+		r.resolveExpression(statement.ReturnValue)
+	case *ast.FunctionStatement:
+		r.postpone(statement.Function)
+	case *ast.StructStatement:
+		r.declare(statement.Name.Value)
+	case *ast.TraitStatement:
+		r.declare(statement.Name.Value)
+	case *ast.ImplStatement:
+		if statement.Trait != nil {
+			r.resolveRead(statement.Trait)
+		}
+		r.resolveRead(statement.Type)
+
+		for _, method := range statement.Methods {
+			r.postpone(method)
+		}
+	case *ast.ImportStatement:
+		for _, name := range declaredNames(statement) {
+			r.declareName(name)
+		}
+	case *ast.ExportStatement:
+		r.resolveStatement(statement.Statement)
+	case *ast.BlockStatement:
+		r.resolveBlock(statement)
+	case *ast.WhileStatement:
+		r.resolveExpression(statement.Condition)
+		r.resolveBlock(statement.Body)
+	case *ast.ForStatement:
+		r.resolveExpression(statement.Iterable)
+
+		s := r.beginScope()
+		slot, _ := r.declare(statement.Variable.Value)
+		r.bind(statement.Variable, slot)
+		r.resolveBlock(statement.Body)
+		r.endScope(s)
+	}
+}
+
+// declareName declares a name the evaluator assigns by name, unless it
+// updates a visible variable.
+func (r *Resolver) declareName(name string) {
+	if _, ok := r.lookup(name); !ok && !r.isGlobal(name) {
+		r.declare(name)
+	}
+}
+
+// postpone postpones resolving a function until the end of the current scope.
+func (r *Resolver) postpone(literal *ast.FunctionLiteral) {
+	scopes := make([]*scope, len(r.scopes))
+	copy(scopes, r.scopes)
+
+	s := r.current()
+	s.pending = append(s.pending, &pendingFunction{literal: literal, scopes: scopes})
+}
+
+func (r *Resolver) resolveFunction(literal *ast.FunctionLiteral, scopes []*scope) {
+	saved := r.scopes
+	r.scopes = scopes
+	r.functions++
+
+	s := r.beginScope()
+	for _, parameter := range literal.Parameters {
+		slot, _ := r.declare(parameter.Value)
+		r.bind(parameter, slot)
+	}
+	r.resolveBlock(literal.Body)
+	r.endScope(s)
+
+	r.functions--
+	r.scopes = saved
+}
+
+func (r *Resolver) resolveExpressions(expressions []ast.Expression) {
+	for _, expression := range expressions {
+		r.resolveExpression(expression)
+	}
+}
+
+func (r *Resolver) resolveExpression(expression ast.Expression) {
+	switch node := expression.(type) {
+	case *ast.Identifier:
+		r.resolveRead(node)
+	case *ast.InterpolatedString:
+		r.resolveExpressions(node.Expressions)
+	case *ast.PrefixExpression:
+		r.resolveExpression(node.Right)
+	case *ast.InfixExpression:
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Right)
+	case *ast.IfExpression:
+		r.resolveExpression(node.Condition)
+		r.resolveBlock(node.Consequence)
+		r.resolveBlock(node.Alternative)
+	case *ast.MatchExpression:
+		r.resolveExpression(node.Subject)
+		for _, arm := range node.Arms {
+			r.resolveMatchArm(arm)
+		}
+	case *ast.SelectExpression:
+		for _, sc := range node.Cases {
+			r.resolveSelectCase(sc)
+		}
+	case *ast.FunctionLiteral:
+		r.postpone(node)
+	case *ast.CallExpression:
+		if name, ok := node.Function.(*ast.Identifier); ok && name.Value == "quote" {
+			// Quoted code is evaluated by name when unquoted
+			return
+		}
+
+		r.resolveExpression(node.Function)
+		r.resolveExpressions(node.Arguments)
+	case *ast.SpawnExpression:
+		r.resolveExpression(node.Call)
+	case *ast.ArrayLiteral:
+		r.resolveExpressions(node.Elements)
+	case *ast.TupleLiteral:
+		r.resolveExpressions(node.Elements)
+	case *ast.MapLiteral:
+		for _, pair := range node.Pairs {
+			r.resolveExpression(pair.Key)
+			r.resolveExpression(pair.Value)
+		}
+	case *ast.IndexExpression:
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Index)
+	case *ast.SliceExpression:
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Start)
+		r.resolveExpression(node.End)
+		r.resolveExpression(node.Step)
+	case *ast.MemberExpression:
+		r.resolveExpression(node.Object)
+	case *ast.YieldExpression:
+		r.resolveExpression(node.Value)
+	case *ast.SpreadExpression:
+		r.resolveExpression(node.Value)
+	}
+}
+
+// resolveMatchArm binds the variables of a pattern in the frame of its arm,
+// in the order they are matched.
+func (r *Resolver) resolveMatchArm(arm *ast.MatchArm) {
+	s := r.beginScope()
+
+	r.resolvePattern(arm.Pattern)
+	r.resolveExpression(arm.Guard)
+
+	if block, ok := arm.Body.(*ast.BlockStatement); ok {
+		r.resolveBlock(block)
+	} else if expression, ok := arm.Body.(ast.Expression); ok {
+		r.resolveExpression(expression)
+	}
+
+	r.endScope(s)
+}
+
+func (r *Resolver) resolvePattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		slot, _ := r.declare(pattern.Name.Value)
+		r.bind(pattern.Name, slot)
+	case *ast.LiteralPattern:
+		r.resolveExpression(pattern.Value)
+	case *ast.RangePattern:
+		r.resolveExpression(pattern.Low)
+		r.resolveExpression(pattern.High)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			r.resolvePattern(element)
+		}
+		if pattern.Rest != nil {
+			slot, _ := r.declare(pattern.Rest.Value)
+			r.bind(pattern.Rest, slot)
+		}
+	case *ast.MapPattern:
+		for _, pair := range pattern.Pairs {
+			r.resolveExpression(pair.Key)
+			r.resolvePattern(pair.Pattern)
+		}
+	}
+}
+
+func (r *Resolver) resolveSelectCase(sc *ast.SelectCase) {
+	if sc.Call != nil {
+		r.resolveExpression(sc.Call)
+	}
+
+	s := r.beginScope()
+	if sc.Name != nil {
+		slot, _ := r.declare(sc.Name.Value)
+		r.bind(sc.Name, slot)
+	}
+
+	if block, ok := sc.Body.(*ast.BlockStatement); ok {
+		r.resolveBlock(block)
+	} else if expression, ok := sc.Body.(ast.Expression); ok {
+		r.resolveExpression(expression)
+	}
+
+	r.endScope(s)
+}
@@ -0,0 +1,105 @@
+package resolver
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vita-dounai/Firework/ast"
+	"github.com/vita-dounai/Firework/lexer"
+	"github.com/vita-dounai/Firework/parser"
+)
+
+func resolveProgram(t *testing.T, input string) (*ast.Program, []string) {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser()
+	p.Init(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors for %q: %v", input, p.Errors())
+	}
+
+	r := NewResolver(func(name string) bool { return name == "len" })
+	r.Resolve(program)
+	return program, r.Errors()
+}
+
+// slots lists the identifiers read in program with their slots as
+// depth:index, globals are listed by name.
+func slots(program *ast.Program) string {
+	identifiers := []string{}
+
+	var collect ast.ModifierFunc
+	collect = func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			if node.Slot == nil {
+				identifiers = append(identifiers, node.Value)
+			} else {
+				identifiers = append(identifiers, fmt.Sprintf("%s@%d:%d", node.Value, node.Slot.Depth, node.Slot.Index))
+			}
+		case *ast.CallExpression:
+			ast.Modify(node.Function, collect)
+			for _, argument := range node.Arguments {
+				ast.Modify(argument, collect)
+			}
+		}
+		return node
+	}
+
+	ast.Modify(program, collect)
+	return strings.Join(identifiers, " ")
+}
+
+func TestResolveSlots(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1; x", "x"},
+		{"fn f(a, b) { a + b }", "a@0:0 b@0:1 a@1:0 b@1:1"},
+		{"fn f(a) { b = a; b }", "a@0:0 a@1:0 b@0:0"},
+		{"fn f(a) { if a { a } }", "a@0:0 a@1:0 a@2:0"},
+		{"fn f() { g = || { n }; n = 1; g() }", "n@2:1 g@0:0"},
+		{"x = 1; fn f(x) { x }", "x@0:0 x@1:0"},
+		{"fn f(l) { for x in l { x + len(l) } }", "l@0:0 l@1:0 x@1:0 len l@3:0"},
+		{"fn f(v) { match v { [a, b] => a + b, n => n } }", "v@0:0 v@1:0 a@0:0 b@0:1 n@0:0"},
+		{"quote(x + y)", "quote x y"},
+	}
+
+	for _, tt := range tests {
+		program, errors := resolveProgram(t, tt.input)
+		if len(errors) != 0 {
+			t.Errorf("unexpected errors for %q: %v", tt.input, errors)
+		}
+
+		if got := slots(program); got != tt.expected {
+			t.Errorf("wrong slots for %q, expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"foobar", []string{"Identifier not found: foobar"}},
+		{"if false { foobar }", []string{"Identifier not found: foobar"}},
+		{"fn f() { x }", []string{"Identifier not found: x"}},
+		{"fn f() { y = x; x = 1 }", []string{"Identifier not found: x"}},
+		{"if true { a = 1 }; a", []string{"Identifier not found: a"}},
+		{"fn f() { x }; x = 1", nil},
+		{"fn f() { g() }; fn g() { 1 }", nil},
+		{"len([])", nil},
+	}
+
+	for _, tt := range tests {
+		_, errors := resolveProgram(t, tt.input)
+		if !reflect.DeepEqual(errors, tt.expected) {
+			t.Errorf("wrong errors for %q, expected=%v, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
@@ -13,11 +13,21 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	// Lexer errors, ILLEGAL is one as well
+	UNTERMINATED_STRING = "UNTERMINATED_STRING"
+	ILLEGAL_ESCAPE      = "ILLEGAL_ESCAPE"
+
 	// Identifiers and literals
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
 	STRING     = "STRING"
 
+	// Parts of a string literal with interpolated expressions,
+	// "a ${x} b ${y} c" is STRING_HEAD x STRING_MIDDLE y STRING_TAIL
+	STRING_HEAD   = "STRING_HEAD"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_TAIL   = "STRING_TAIL"
+
 	// Operators
 	ASSIGN      = "="
 	PLUS        = "+"
@@ -34,6 +44,19 @@ const (
 	NOT_EQ      = "!="
 	VERTICAL    = "|"
 	PERCENT     = "%"
+	AMPERSAND   = "&"
+	CARET       = "^"
+	TILDE       = "~"
+	LSHIFT      = "<<"
+	RSHIFT      = ">>"
+	// Bitwise or, `|` already delimits function parameters
+	BOR       = "BOR"
+	FAT_ARROW = "=>"
+	DOTDOT    = ".."
+	DOTDOT_EQ = "..="
+	ELLIPSIS  = "..."
+	DOT       = "."
+	ARROW     = "->"
 
 	// Delimiters
 	COMMA     = ","
@@ -56,6 +79,19 @@ const (
 	BREAK    = "BREAK"
 	CONTINUE = "CONTINUE"
 	MACRO    = "MACRO"
+	MATCH    = "MATCH"
+	FN       = "FN"
+	FOR      = "FOR"
+	IN       = "IN"
+	YIELD    = "YIELD"
+	SPAWN    = "SPAWN"
+	SELECT   = "SELECT"
+	IMPORT   = "IMPORT"
+	FROM     = "FROM"
+	EXPORT   = "EXPORT"
+	STRUCT   = "STRUCT"
+	IMPL     = "IMPL"
+	TRAIT    = "TRAIT"
 )
 
 var keywords = map[string]TokenType{
@@ -68,6 +104,20 @@ var keywords = map[string]TokenType{
 	"break":    BREAK,
 	"continue": CONTINUE,
 	"macro":    MACRO,
+	"bor":      BOR,
+	"match":    MATCH,
+	"fn":       FN,
+	"for":      FOR,
+	"in":       IN,
+	"yield":    YIELD,
+	"spawn":    SPAWN,
+	"select":   SELECT,
+	"import":   IMPORT,
+	"from":     FROM,
+	"export":   EXPORT,
+	"struct":   STRUCT,
+	"impl":     IMPL,
+	"trait":    TRAIT,
 }
 
 func LookupIdentifier(identifier string) TokenType {